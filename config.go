@@ -1,21 +1,118 @@
 package main
 
 import (
+	"fmt"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/joeshaw/envdecode"
+	"github.com/kpeu3i/radio-streamer/radio"
+	"github.com/kpeu3i/radio-streamer/streaming"
 )
 
 type Config struct {
 	HTTPServer struct {
-		Address string `env:"HTTP_SERVER_ADDRESS,default=:7070"`
+		Address    string `env:"HTTP_SERVER_ADDRESS,default=:7070"`
+		VolumeStep int    `env:"HTTP_SERVER_VOLUME_STEP,default=1"`
 	}
 
 	MQTTServer struct {
-		Address  string `env:"MQTT_SERVER_ADDRESS,default=localhost:1883"`
-		User     string `env:"MQTT_SERVER_USER,default=admin"`
-		Password string `env:"MQTT_SERVER_PASSWORD,default=admin"`
-		Topic    string `env:"MQTT_SERVER_Topic,default=zigbee2mqtt/0x00124b000cc8d641/action"`
+		Address         string        `env:"MQTT_SERVER_ADDRESS,default=localhost:1883"`
+		User            string        `env:"MQTT_SERVER_USER,default=admin"`
+		Password        string        `env:"MQTT_SERVER_PASSWORD,default=admin"`
+		Topic           string        `env:"MQTT_SERVER_Topic,default=zigbee2mqtt/0x00124b000cc8d641/action"`
+		NowPlayingTopic string        `env:"MQTT_SERVER_NOW_PLAYING_TOPIC,default=radio-streamer/now-playing"`
+		LevelsTopic     string        `env:"MQTT_SERVER_LEVELS_TOPIC,default=radio-streamer/levels"`
+		LevelsInterval  time.Duration `env:"MQTT_SERVER_LEVELS_INTERVAL,default=1s"`
+		VolumeStep      int           `env:"MQTT_SERVER_VOLUME_STEP,default=1"`
+		Zones           string        `env:"MQTT_SERVER_ZONES"`
+	}
+
+	Output struct {
+		Sink    string `env:"OUTPUT_SINK,default=oto"`
+		WAVPath string `env:"OUTPUT_WAV_PATH,default=radio.wav"`
+		Command string `env:"OUTPUT_COMMAND,default=aplay -t raw -f S16_LE -c 2 -r 44100"`
+		Zones   string `env:"OUTPUT_ZONES"`
+	}
+
+	Reconnect struct {
+		MaxAttempts  int           `env:"RECONNECT_MAX_ATTEMPTS,default=10"`
+		InitialDelay time.Duration `env:"RECONNECT_INITIAL_DELAY,default=1s"`
+		MaxDelay     time.Duration `env:"RECONNECT_MAX_DELAY,default=30s"`
+		Multiplier   float64       `env:"RECONNECT_MULTIPLIER,default=2"`
+		Jitter       float64       `env:"RECONNECT_JITTER,default=0.2"`
+	}
+
+	Watchdog struct {
+		StallTimeout     time.Duration `env:"WATCHDOG_STALL_TIMEOUT,default=15s"`
+		StallAction      string        `env:"WATCHDOG_STALL_ACTION,default=reconnect"`
+		SilenceTimeout   time.Duration `env:"WATCHDOG_SILENCE_TIMEOUT,default=2m"`
+		SilenceThreshold float64       `env:"WATCHDOG_SILENCE_THRESHOLD,default=-60"`
+		SilenceAction    string        `env:"WATCHDOG_SILENCE_ACTION,default=reconnect"`
+	}
+
+	Buffer struct {
+		Size         time.Duration `env:"BUFFER_SIZE,default=10s"`
+		Prefill      time.Duration `env:"BUFFER_PREFILL,default=2s"`
+		LowWatermark time.Duration `env:"BUFFER_LOW_WATERMARK,default=0s"`
+		TimeShift    time.Duration `env:"BUFFER_TIME_SHIFT,default=0s"`
+	}
+
+	Crossfade struct {
+		Duration time.Duration `env:"CROSSFADE_DURATION,default=2s"`
+	}
+
+	Volume struct {
+		Steps int     `env:"VOLUME_STEPS,default=20"`
+		MinDB float64 `env:"VOLUME_MIN_DB,default=-50"`
+	}
+
+	Normalization struct {
+		Enabled    bool          `env:"NORMALIZATION_ENABLED,default=false"`
+		Target     float64       `env:"NORMALIZATION_TARGET,default=-18"`
+		MaxGain    float64       `env:"NORMALIZATION_MAX_GAIN,default=12"`
+		Ceiling    float64       `env:"NORMALIZATION_CEILING,default=-1"`
+		Adaptation time.Duration `env:"NORMALIZATION_ADAPTATION,default=20s"`
+	}
+
+	NightMode struct {
+		Start      string        `env:"NIGHT_MODE_START"`
+		End        string        `env:"NIGHT_MODE_END"`
+		Threshold  float64       `env:"NIGHT_MODE_THRESHOLD,default=-30"`
+		Ratio      float64       `env:"NIGHT_MODE_RATIO,default=4"`
+		Attack     time.Duration `env:"NIGHT_MODE_ATTACK,default=10ms"`
+		Release    time.Duration `env:"NIGHT_MODE_RELEASE,default=200ms"`
+		MakeupGain float64       `env:"NIGHT_MODE_MAKEUP_GAIN,default=9"`
+		Ceiling    float64       `env:"NIGHT_MODE_CEILING,default=-1"`
+	}
+
+	Recording struct {
+		Dir         string        `env:"RECORDING_DIR,default=recordings"`
+		MaxDuration time.Duration `env:"RECORDING_MAX_DURATION,default=4h"`
+		QuotaMB     int64         `env:"RECORDING_QUOTA_MB,default=2048"`
+		SplitTracks bool          `env:"RECORDING_SPLIT_TRACKS,default=false"`
+		Follow      bool          `env:"RECORDING_FOLLOW,default=false"`
+	}
+
+	Clip struct {
+		Dir       string        `env:"CLIP_DIR,default=clips"`
+		Duration  time.Duration `env:"CLIP_DURATION,default=60s"`
+		After     time.Duration `env:"CLIP_AFTER,default=0s"`
+		Transcode string        `env:"CLIP_TRANSCODE,default=ffmpeg -hide_banner -loglevel error -i pipe:0 -f mp3 pipe:1"`
+	}
+
+	Sync struct {
+		Mode    string        `env:"SYNC_MODE"`
+		Address string        `env:"SYNC_ADDRESS,default=:7071"`
+		Zone    string        `env:"SYNC_ZONE"`
+		Delay   time.Duration `env:"SYNC_DELAY,default=500ms"`
+		Latency time.Duration `env:"SYNC_LATENCY,default=0s"`
+	}
+
+	Fade struct {
+		Power  time.Duration `env:"POWER_FADE_DURATION,default=1s"`
+		Volume time.Duration `env:"VOLUME_FADE_DURATION,default=200ms"`
 	}
 
 	ErrorHandling struct {
@@ -31,5 +128,107 @@ func newConfig() (*Config, error) {
 		return nil, err
 	}
 
+	for _, action := range []string{config.Watchdog.StallAction, config.Watchdog.SilenceAction} {
+		_, err = radio.ParseWatchdogAction(action)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = config.VolumeCurve().Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	if config.Clip.After < 0 || config.Clip.After > config.Clip.Duration {
+		return nil, fmt.Errorf("invalid clip after (up to the clip duration): %s", config.Clip.After)
+	}
+
 	return &config, nil
 }
+
+func (c *Config) VolumeCurve() streaming.VolumeCurve {
+	return streaming.VolumeCurve{Steps: c.Volume.Steps, MinDB: c.Volume.MinDB}
+}
+
+// ZoneOutput is a zone of OUTPUT_ZONES and its sink, with the WAV path or the
+// command of the sink when set.
+type ZoneOutput struct {
+	Name string
+	Sink string
+	Arg  string
+}
+
+// ZoneOutputs parses OUTPUT_ZONES: zones separated by semicolons, like
+// "living=oto;kitchen=command:aplay -D plughw:1 -t raw -f S16_LE -c 2 -r 44100".
+// Without zones there is a single unnamed zone playing to OUTPUT_SINK.
+func (c *Config) ZoneOutputs() ([]ZoneOutput, error) {
+	if strings.TrimSpace(c.Output.Zones) == "" {
+		return []ZoneOutput{{Sink: c.Output.Sink}}, nil
+	}
+
+	var outputs []ZoneOutput
+
+	names := make(map[string]bool)
+	wavPaths := make(map[string]bool)
+	oto := false
+
+	for _, spec := range strings.Split(c.Output.Zones, ";") {
+		if strings.TrimSpace(spec) == "" {
+			continue
+		}
+
+		parts := strings.SplitN(spec, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid zone (expected name=sink): %s", spec)
+		}
+
+		name := strings.TrimSpace(parts[0])
+		if name == "" || name == streaming.ZoneGroupAll || strings.ContainsAny(name, ", /") {
+			return nil, fmt.Errorf("invalid zone name: %q", name)
+		}
+
+		if names[name] {
+			return nil, fmt.Errorf("duplicate zone: %s", name)
+		}
+
+		names[name] = true
+
+		sink := strings.SplitN(strings.TrimSpace(parts[1]), ":", 2)
+		output := ZoneOutput{Name: name, Sink: sink[0]}
+		if len(sink) == 2 {
+			output.Arg = strings.TrimSpace(sink[1])
+		}
+
+		// oto allows only one context per process.
+		if output.Sink == "oto" {
+			if oto {
+				return nil, fmt.Errorf("only one zone can use the oto sink: %s", name)
+			}
+
+			oto = true
+		}
+
+		// A WAV file is written by a single zone.
+		if output.Sink == "wav" {
+			if output.Arg == "" {
+				output.Arg = c.Output.WAVPath
+			}
+
+			path := filepath.Clean(output.Arg)
+			if wavPaths[path] {
+				return nil, fmt.Errorf("duplicate WAV path: %s: %s", name, output.Arg)
+			}
+
+			wavPaths[path] = true
+		}
+
+		outputs = append(outputs, output)
+	}
+
+	if len(outputs) == 0 {
+		return nil, fmt.Errorf("no zones: %s", c.Output.Zones)
+	}
+
+	return outputs, nil
+}
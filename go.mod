@@ -5,6 +5,7 @@ go 1.16
 require (
 	github.com/eclipse/paho.mqtt.golang v1.3.3
 	github.com/hajimehoshi/oto/v2 v2.1.0-alpha.4
+	github.com/jfreymuth/vorbis v1.0.2
 	github.com/joeshaw/envdecode v0.0.0-20200121155833-099f1fc765bd
 	github.com/tosone/minimp3 v1.0.1
 	gopkg.in/yaml.v3 v3.0.0-20210107192922-496545a6307b
@@ -1,5 +0,0 @@
-package httpapi
-
-const (
-	volumeStep = 0.1
-)
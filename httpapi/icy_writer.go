@@ -0,0 +1,95 @@
+package httpapi
+
+import (
+	"io"
+)
+
+const (
+	icyMetadataInterval  = 16000
+	icyMetadataBlockSize = 16
+	icyMetadataMaxBlocks = 255
+)
+
+// icyWriter interleaves the metadata blocks into the audio data every
+// interval bytes, for the clients asking for them. The title is only sent
+// when it changes, an empty block is sent otherwise.
+type icyWriter struct {
+	writer   io.Writer
+	interval int
+	written  int
+	title    func() string
+	sent     string
+	started  bool
+}
+
+func newICYWriter(writer io.Writer, interval int, title func() string) *icyWriter {
+	return &icyWriter{writer: writer, interval: interval, title: title}
+}
+
+func (w *icyWriter) Write(p []byte) (int, error) {
+	if w.interval <= 0 {
+		return w.writer.Write(p)
+	}
+
+	total := 0
+
+	for len(p) > 0 {
+		n := w.interval - w.written
+		if n > len(p) {
+			n = len(p)
+		}
+
+		written, err := w.writer.Write(p[:n])
+		total += written
+		w.written += written
+
+		if err != nil {
+			return total, err
+		}
+
+		p = p[n:]
+
+		if w.written == w.interval {
+			w.written = 0
+
+			err = w.writeMetadata()
+			if err != nil {
+				return total, err
+			}
+		}
+	}
+
+	return total, nil
+}
+
+func (w *icyWriter) writeMetadata() error {
+	title := w.title()
+	if w.started && title == w.sent {
+		_, err := w.writer.Write([]byte{0})
+
+		return err
+	}
+
+	w.started, w.sent = true, title
+
+	_, err := w.writer.Write(icyMetadataBlock(title))
+
+	return err
+}
+
+// icyMetadataBlock encodes the title as a length byte, in blocks of 16
+// bytes, followed by the blocks padded with zeros.
+func icyMetadataBlock(title string) []byte {
+	metadata := "StreamTitle='" + title + "';"
+	if max := icyMetadataMaxBlocks * icyMetadataBlockSize; len(metadata) > max {
+		metadata = metadata[:max-2] + "';"
+	}
+
+	blocks := (len(metadata) + icyMetadataBlockSize - 1) / icyMetadataBlockSize
+
+	block := make([]byte, 1+blocks*icyMetadataBlockSize)
+	block[0] = byte(blocks)
+	copy(block[1:], metadata)
+
+	return block
+}
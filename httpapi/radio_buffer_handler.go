@@ -0,0 +1,21 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/kpeu3i/radio-streamer/streaming"
+)
+
+func RadioBufferHandler(service *streaming.Service) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		writer.Header().Set("Content-Type", "application/json")
+
+		err := json.NewEncoder(writer).Encode(service.BufferStats())
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusInternalServerError)
+
+			return
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/kpeu3i/radio-streamer/radio"
+	"github.com/kpeu3i/radio-streamer/streaming"
+)
+
+// RadioClipHandler saves the last minutes played, and the next ones with
+// /radio/clip?after=30s (up to the clip duration), then responds once the
+// clip is saved. The zones of a group are cut at the same moment, the clips
+// are joined by zone, and the zones failing are reported like ZonesHandler.
+func RadioClipHandler(zones *streaming.Zones, after time.Duration) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		group, err := zones.Group(request.URL.Query().Get("zone"))
+		if err != nil {
+			http.Error(writer, err.Error(), zoneErrorStatus(err))
+
+			return
+		}
+
+		after := after
+		if value := request.URL.Query().Get("after"); value != "" {
+			after, err = time.ParseDuration(value)
+			if err != nil || after < 0 {
+				http.Error(writer, "invalid after", http.StatusBadRequest)
+
+				return
+			}
+		}
+
+		clips, err := group.SaveClip(after)
+
+		var errs streaming.ZoneErrors
+		if errors.As(err, &errs) {
+			results := make(map[string]interface{}, len(clips))
+			for name, clip := range clips {
+				results[name] = clip
+			}
+
+			writeZoneErrors(writer, group, errs, results, clipErrorStatus)
+
+			return
+		}
+
+		if err != nil {
+			http.Error(writer, err.Error(), clipErrorStatus(err))
+
+			return
+		}
+
+		var response interface{} = clips
+		if len(group) == 1 {
+			response = clips[group[0].Name]
+		}
+
+		writer.Header().Set("Content-Type", "application/json")
+
+		err = json.NewEncoder(writer).Encode(response)
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusInternalServerError)
+
+			return
+		}
+	}
+}
+
+func clipErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, streaming.ErrClipTooLong):
+		return http.StatusBadRequest
+	case errors.Is(err, radio.ErrNotPlaying) || errors.Is(err, radio.ErrNoReplay):
+		return http.StatusConflict
+	}
+
+	return http.StatusInternalServerError
+}
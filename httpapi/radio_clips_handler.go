@@ -0,0 +1,48 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/kpeu3i/radio-streamer/streaming"
+)
+
+// RadioClipsHandler lists the saved clips, and downloads one of them with
+// /radio/clips/<name>.
+func RadioClipsHandler(service *streaming.Service) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		name := strings.TrimPrefix(request.URL.Path, "/radio/clips")
+		name = strings.TrimPrefix(name, "/")
+
+		if name != "" {
+			path, err := service.ClipPath(name)
+			if err != nil {
+				http.Error(writer, err.Error(), http.StatusNotFound)
+
+				return
+			}
+
+			writer.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+			http.ServeFile(writer, request, path)
+
+			return
+		}
+
+		clips, err := service.Clips()
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusInternalServerError)
+
+			return
+		}
+
+		writer.Header().Set("Content-Type", "application/json")
+
+		err = json.NewEncoder(writer).Encode(clips)
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusInternalServerError)
+
+			return
+		}
+	}
+}
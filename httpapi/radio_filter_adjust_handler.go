@@ -0,0 +1,34 @@
+package httpapi
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/kpeu3i/radio-streamer/streaming"
+)
+
+// RadioFilterAdjustHandler changes the gain of a named filter, like
+// /radio/filters/adjust?name=bass&gain=3.
+func RadioFilterAdjustHandler(service *streaming.Service) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		gain, err := strconv.ParseFloat(request.URL.Query().Get("gain"), 64)
+		if err != nil {
+			http.Error(writer, "invalid gain", http.StatusBadRequest)
+
+			return
+		}
+
+		err = service.AdjustFilterGain(request.URL.Query().Get("name"), gain)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, streaming.ErrFilterNotFound) {
+				status = http.StatusNotFound
+			}
+
+			http.Error(writer, err.Error(), status)
+
+			return
+		}
+	}
+}
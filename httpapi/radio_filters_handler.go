@@ -0,0 +1,32 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/kpeu3i/radio-streamer/streaming"
+)
+
+func RadioFiltersHandler(service *streaming.Service) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		filters, err := service.Filters()
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusInternalServerError)
+
+			return
+		}
+
+		if filters == nil {
+			filters = []streaming.Filter{}
+		}
+
+		writer.Header().Set("Content-Type", "application/json")
+
+		err = json.NewEncoder(writer).Encode(filters)
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusInternalServerError)
+
+			return
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package httpapi
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/kpeu3i/radio-streamer/streaming"
+)
+
+// RadioListenHandler relays the stream played like an Icecast mount, with the
+// ICY headers of the station and the titles interleaved for the clients
+// sending "Icy-MetaData: 1". The response goes on across the station
+// switches and ends when the format changes or the radio stops.
+func RadioListenHandler(service *streaming.Service) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		flusher, ok := writer.(http.Flusher)
+		if !ok {
+			http.Error(writer, "streaming not supported", http.StatusInternalServerError)
+
+			return
+		}
+
+		listener, err := service.Relay()
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusServiceUnavailable)
+
+			return
+		}
+		defer listener.Close()
+
+		nowPlaying := service.NowPlaying()
+
+		header := writer.Header()
+		header.Set("Content-Type", relayContentType(listener.Format()))
+		header.Set("Cache-Control", "no-cache, no-store")
+		header.Set("icy-name", nowPlaying.Name)
+		header.Set("icy-genre", nowPlaying.Genre)
+		header.Set("icy-pub", "0")
+
+		if nowPlaying.Bitrate > 0 {
+			header.Set("icy-br", strconv.Itoa(nowPlaying.Bitrate))
+		}
+
+		interval := 0
+		if request.Header.Get("Icy-MetaData") == "1" {
+			interval = icyMetadataInterval
+			header.Set("icy-metaint", strconv.Itoa(interval))
+		}
+
+		writer.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		icy := newICYWriter(writer, interval, func() string {
+			return service.NowPlaying().Title
+		})
+
+		for {
+			select {
+			case <-request.Context().Done():
+				return
+			case chunk, ok := <-listener.Chunks():
+				if !ok {
+					return
+				}
+
+				_, err = icy.Write(chunk)
+				if err != nil {
+					return
+				}
+
+				flusher.Flush()
+			}
+		}
+	}
+}
+
+func relayContentType(format string) string {
+	switch format[strings.LastIndex(format, "/")+1:] {
+	case "mp3":
+		return "audio/mpeg"
+	case "aac":
+		return "audio/aac"
+	case "ogg":
+		return "application/ogg"
+	default:
+		return "application/octet-stream"
+	}
+}
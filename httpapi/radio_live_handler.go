@@ -0,0 +1,18 @@
+package httpapi
+
+import (
+	"net/http"
+
+	"github.com/kpeu3i/radio-streamer/streaming"
+)
+
+func RadioLiveHandler(service *streaming.Service) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		err := service.GoLive()
+		if err != nil {
+			http.Error(writer, err.Error(), timeShiftStatus(err))
+
+			return
+		}
+	}
+}
@@ -0,0 +1,13 @@
+package httpapi
+
+import (
+	"net/http"
+
+	"github.com/kpeu3i/radio-streamer/streaming"
+)
+
+func RadioNightModeHandler(service *streaming.Service) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		service.ToggleNightMode()
+	}
+}
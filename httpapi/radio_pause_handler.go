@@ -0,0 +1,28 @@
+package httpapi
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/kpeu3i/radio-streamer/radio"
+	"github.com/kpeu3i/radio-streamer/streaming"
+)
+
+func RadioPauseHandler(service *streaming.Service) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		err := service.TogglePause()
+		if err != nil {
+			http.Error(writer, err.Error(), timeShiftStatus(err))
+
+			return
+		}
+	}
+}
+
+func timeShiftStatus(err error) int {
+	if errors.Is(err, radio.ErrNotPlaying) || errors.Is(err, radio.ErrNoTimeShift) {
+		return http.StatusConflict
+	}
+
+	return http.StatusInternalServerError
+}
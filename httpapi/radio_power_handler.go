@@ -1,22 +1,39 @@
 package httpapi
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/kpeu3i/radio-streamer/streaming"
 )
 
-func RadioPowerHandler(service *streaming.Service) http.HandlerFunc {
+// RadioPowerHandler toggles the power of the zones of the zone query
+// parameter together. When only some of the zones fail to start, the response
+// is a 207 with the status of each zone.
+func RadioPowerHandler(zones *streaming.Zones) http.HandlerFunc {
 	return func(writer http.ResponseWriter, request *http.Request) {
-		if service.IsRadioPlaying() {
-			service.StopRadio()
-		} else {
-			err := service.PlayRadio()
-			if err != nil {
-				http.Error(writer, err.Error(), http.StatusInternalServerError)
-
-				return
-			}
+		group, err := zones.Group(request.URL.Query().Get("zone"))
+		if err != nil {
+			http.Error(writer, err.Error(), zoneErrorStatus(err))
+
+			return
+		}
+
+		err = group.TogglePower()
+
+		var errs streaming.ZoneErrors
+		if errors.As(err, &errs) {
+			writeZoneErrors(writer, group, errs, nil, func(err error) int {
+				return http.StatusInternalServerError
+			})
+
+			return
+		}
+
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusInternalServerError)
+
+			return
 		}
 	}
 }
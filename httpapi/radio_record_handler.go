@@ -0,0 +1,25 @@
+package httpapi
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/kpeu3i/radio-streamer/radio"
+	"github.com/kpeu3i/radio-streamer/streaming"
+)
+
+func RadioRecordHandler(service *streaming.Service) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		_, err := service.ToggleRecording()
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, radio.ErrNotPlaying) {
+				status = http.StatusConflict
+			}
+
+			http.Error(writer, err.Error(), status)
+
+			return
+		}
+	}
+}
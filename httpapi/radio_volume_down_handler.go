@@ -6,9 +6,9 @@ import (
 	"github.com/kpeu3i/radio-streamer/streaming"
 )
 
-func VolumeDownHandler(service *streaming.Service) http.HandlerFunc {
+func VolumeDownHandler(service *streaming.Service, steps int) http.HandlerFunc {
 	return func(writer http.ResponseWriter, request *http.Request) {
-		err := service.DownVolume(volumeStep)
+		err := service.DownVolume(steps)
 		if err != nil {
 			http.Error(writer, err.Error(), http.StatusInternalServerError)
 
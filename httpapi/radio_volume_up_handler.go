@@ -6,9 +6,9 @@ import (
 	"github.com/kpeu3i/radio-streamer/streaming"
 )
 
-func VolumeUpHandler(service *streaming.Service) http.HandlerFunc {
+func VolumeUpHandler(service *streaming.Service, steps int) http.HandlerFunc {
 	return func(writer http.ResponseWriter, request *http.Request) {
-		err := service.UpVolume(volumeStep)
+		err := service.UpVolume(steps)
 		if err != nil {
 			http.Error(writer, err.Error(), http.StatusInternalServerError)
 
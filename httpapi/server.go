@@ -2,6 +2,7 @@ package httpapi
 
 import (
 	"context"
+	"net"
 	"net/http"
 )
 
@@ -9,20 +10,29 @@ type Server struct {
 	address string
 	mux     *http.ServeMux
 	server  *http.Server
+	cancel  context.CancelFunc
 }
 
 func NewServer(address string) *Server {
 	mux := http.NewServeMux()
 
+	// The requests are canceled on close, so the streaming responses end
+	// instead of holding the shutdown.
+	ctx, cancel := context.WithCancel(context.Background())
+
 	httpServer := &http.Server{
 		Addr:    address,
 		Handler: mux,
+		BaseContext: func(net.Listener) context.Context {
+			return ctx
+		},
 	}
 
 	server := &Server{
 		address: address,
 		mux:     mux,
 		server:  httpServer,
+		cancel:  cancel,
 	}
 
 	return server
@@ -48,5 +58,7 @@ func (s *Server) Listen() error {
 }
 
 func (s *Server) Close() error {
+	s.cancel()
+
 	return s.server.Shutdown(context.Background())
 }
@@ -0,0 +1,212 @@
+package httpapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/kpeu3i/radio-streamer/streaming"
+)
+
+// ServiceHandler makes the handler of the service of a zone.
+type ServiceHandler func(service *streaming.Service) http.HandlerFunc
+
+// ZoneHandler serves the request with the handler of the zone of the zone
+// query parameter, or of the default zone.
+func ZoneHandler(zones *streaming.Zones, handler ServiceHandler) http.HandlerFunc {
+	handlers := zoneHandlers(zones, handler)
+
+	return func(writer http.ResponseWriter, request *http.Request) {
+		zone, err := zones.Zone(request.URL.Query().Get("zone"))
+		if err != nil {
+			http.Error(writer, err.Error(), zoneErrorStatus(err))
+
+			return
+		}
+
+		handlers[zone.Name](writer, request)
+	}
+}
+
+// ZonesHandler serves the request with the handlers of the zones of the zone
+// query parameter: a zone, zones separated by commas or "all". A single zone
+// responds as it is. The responses of a group are joined in a JSON object by
+// zone. When only some of the zones fail, the others have acted already, so
+// the response is a 207 with the status of each zone.
+func ZonesHandler(zones *streaming.Zones, handler ServiceHandler) http.HandlerFunc {
+	handlers := zoneHandlers(zones, handler)
+
+	return func(writer http.ResponseWriter, request *http.Request) {
+		group, err := zones.Group(request.URL.Query().Get("zone"))
+		if err != nil {
+			http.Error(writer, err.Error(), zoneErrorStatus(err))
+
+			return
+		}
+
+		if len(group) == 1 {
+			handlers[group[0].Name](writer, request)
+
+			return
+		}
+
+		responses := make(map[string]*zoneResponse, len(group))
+		var failed *zoneResponse
+
+		for _, zone := range group {
+			response := newZoneResponse()
+			handlers[zone.Name](response, request)
+
+			if response.failed() && failed == nil {
+				failed = response
+			}
+
+			responses[zone.Name] = response
+		}
+
+		if failed != nil {
+			writeZoneResults(writer, responses, failed)
+
+			return
+		}
+
+		bodies := make(map[string]json.RawMessage, len(responses))
+		for name, response := range responses {
+			if body := response.json(); body != nil {
+				bodies[name] = body
+			}
+		}
+
+		if len(bodies) == 0 {
+			return
+		}
+
+		writeJSON(writer, http.StatusOK, bodies)
+	}
+}
+
+// zoneResult is the outcome of a zone of a group, some of which failed.
+type zoneResult struct {
+	Status   int             `json:"status"`
+	Error    string          `json:"error,omitempty"`
+	Response json.RawMessage `json:"response,omitempty"`
+}
+
+// writeZoneResults fails the request like its first failing zone when all
+// the zones failed, and reports the status of each zone otherwise.
+func writeZoneResults(writer http.ResponseWriter, responses map[string]*zoneResponse, failed *zoneResponse) {
+	results := make(map[string]zoneResult, len(responses))
+	succeeded := false
+
+	for name, response := range responses {
+		result := zoneResult{Status: response.status}
+		if response.failed() {
+			result.Error = string(bytes.TrimSpace(response.body.Bytes()))
+		} else {
+			result.Response = response.json()
+			succeeded = true
+		}
+
+		results[name] = result
+	}
+
+	if !succeeded {
+		http.Error(writer, string(bytes.TrimSpace(failed.body.Bytes())), failed.status)
+
+		return
+	}
+
+	writeJSON(writer, http.StatusMultiStatus, results)
+}
+
+// writeZoneErrors reports a group action some zones of which failed like
+// ZonesHandler, with the results of the other zones.
+func writeZoneErrors(writer http.ResponseWriter, group streaming.Group, errs streaming.ZoneErrors, results map[string]interface{}, errorStatus func(err error) int) {
+	responses := make(map[string]*zoneResponse, len(group))
+	var failed *zoneResponse
+
+	for _, zone := range group {
+		response := newZoneResponse()
+
+		if err, ok := errs[zone.Name]; ok {
+			http.Error(response, err.Error(), errorStatus(err))
+
+			if failed == nil {
+				failed = response
+			}
+		} else if result, ok := results[zone.Name]; ok {
+			writeJSON(response, http.StatusOK, result)
+		}
+
+		responses[zone.Name] = response
+	}
+
+	writeZoneResults(writer, responses, failed)
+}
+
+func writeJSON(writer http.ResponseWriter, status int, v interface{}) {
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(status)
+
+	err := json.NewEncoder(writer).Encode(v)
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+}
+
+func zoneHandlers(zones *streaming.Zones, handler ServiceHandler) map[string]http.HandlerFunc {
+	handlers := make(map[string]http.HandlerFunc)
+	for _, zone := range zones.All() {
+		handlers[zone.Name] = handler(zone.Service)
+	}
+
+	return handlers
+}
+
+func zoneErrorStatus(err error) int {
+	if errors.Is(err, streaming.ErrZoneNotFound) {
+		return http.StatusNotFound
+	}
+
+	return http.StatusInternalServerError
+}
+
+// zoneResponse keeps the response of a zone of a group.
+type zoneResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newZoneResponse() *zoneResponse {
+	return &zoneResponse{header: make(http.Header), status: http.StatusOK}
+}
+
+func (r *zoneResponse) Header() http.Header {
+	return r.header
+}
+
+func (r *zoneResponse) Write(p []byte) (int, error) {
+	return r.body.Write(p)
+}
+
+func (r *zoneResponse) WriteHeader(status int) {
+	r.status = status
+}
+
+func (r *zoneResponse) failed() bool {
+	return r.status >= http.StatusBadRequest
+}
+
+// json returns the body, if it is JSON.
+func (r *zoneResponse) json() json.RawMessage {
+	body := bytes.TrimSpace(r.body.Bytes())
+	if len(body) == 0 || !json.Valid(body) {
+		return nil
+	}
+
+	return body
+}
@@ -1,8 +1,11 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
 	"path"
@@ -19,7 +22,9 @@ import (
 const (
 	configFilepath = "config.yaml"
 
-	appRestartIntervalHours = 3
+	appRestartIntervalHours    = 3
+	appRestartRecordingWait    = time.Minute
+	appRestartMaxRecordingWait = 4 * time.Hour
 )
 
 func main() {
@@ -31,18 +36,53 @@ func main() {
 		log.Fatalf("[ERROR] %v", err)
 	}
 
+	// A follower only plays the audio of its leader.
+	if appConfig.Sync.Mode == "follower" {
+		runSyncFollower(appConfig, signals)
+
+		return
+	}
+
 	configStorage := streaming.NewConfigStorage(configFilePath())
 	streamingServiceConfig, err := configStorage.Load()
 	if err != nil {
 		log.Fatalf("[ERROR] %v", err)
 	}
 
+	zoneOutputs, err := appConfig.ZoneOutputs()
+	if err != nil {
+		log.Fatalf("[ERROR] %v", err)
+	}
+
+	// The sinks outlive the player restarts, as oto allows only one context per process.
+	sinks := make(map[string]radio.Sink, len(zoneOutputs))
+	for _, output := range zoneOutputs {
+		sinks[output.Name], err = newSink(appConfig, output)
+		if err != nil {
+			log.Fatalf("[ERROR] %v", err)
+		}
+	}
+
+	switch appConfig.Sync.Mode {
+	case "":
+	case "leader":
+		err = startSyncLeader(appConfig, zoneOutputs, sinks)
+		if err != nil {
+			log.Fatalf("[ERROR] %v", err)
+		}
+	default:
+		log.Fatalf("[ERROR] unknown sync mode: %s", appConfig.Sync.Mode)
+	}
+
+	nightSchedule, err := streaming.ParseNightSchedule(appConfig.NightMode.Start, appConfig.NightMode.End)
+	if err != nil {
+		log.Fatalf("[ERROR] %v", err)
+	}
+
 	errs := make(chan error)
-	wasPlaying := false
+	wasPlaying := make(map[string]bool)
 
 	for {
-		radioPlayer := radio.NewPlayer(streamingServiceConfig.Streams...)
-		radioPlayer.OnError(func(err error) { errs <- err })
 		httpServer := httpapi.NewServer(appConfig.HTTPServer.Address)
 		mqttListener := mqttapi.NewListener(
 			appConfig.MQTTServer.Address,
@@ -50,13 +90,35 @@ func main() {
 			appConfig.MQTTServer.Password,
 			appConfig.MQTTServer.Topic,
 		)
-		service := streaming.NewService(configStorage, radioPlayer)
+
+		zones := streaming.NewZones()
+
+		for _, output := range zoneOutputs {
+			radioPlayer := newRadioPlayer(appConfig, output.Name, streamingServiceConfig.StreamURLs(), sinks[output.Name], errs)
+
+			var storage streaming.ConfigStorage = configStorage
+			if output.Name != "" {
+				storage = configStorage.Zone(output.Name)
+			}
+
+			service := newService(appConfig, storage, radioPlayer, mqttListener, output.Name)
+			service.SetNightSchedule(nightSchedule)
+			zones.Add(output.Name, service)
+		}
+
+		mqttGroup, err := zones.Group(appConfig.MQTTServer.Zones)
+		if err != nil {
+			log.Fatalf("[ERROR] %v", err)
+		}
+
 		panicHandler := func(v interface{}) { errs <- fmt.Errorf("%v", v) }
 
-		if wasPlaying {
-			err = service.PlayRadio()
-			if err != nil {
-				log.Fatalf("[ERROR] %v", err)
+		for _, zone := range zones.All() {
+			if wasPlaying[zone.Name] {
+				err = zone.Service.PlayRadio()
+				if err != nil {
+					log.Fatalf("[ERROR] %v", err)
+				}
 			}
 		}
 
@@ -68,59 +130,190 @@ func main() {
 			log.Println("Starting application...")
 			log.Printf("Scheduled application restart time: %s", now.Add(nextRestartDuration).Format(time.RFC3339))
 
-			err := runApp(httpServer, mqttListener, service, panicHandler)
+			err := runApp(appConfig, httpServer, mqttListener, zones, mqttGroup, panicHandler)
 			if err != nil {
 				errs <- err
 			}
 		}()
 
-		select {
-		case <-signals:
-			log.Println("Got termination signal")
-			log.Println("Stopping application...")
+		var deferred time.Duration
 
-			_ = stopApp(httpServer, mqttListener, service)
+		for restarted := false; !restarted; {
+			select {
+			case <-signals:
+				log.Println("Got termination signal")
+				log.Println("Stopping application...")
+
+				_ = stopApp(httpServer, mqttListener, zones)
+				closeSinks(sinks)
 
-			return
-		case <-restartTimer.C:
-			log.Println("Got restart signal")
-			log.Println("Stopping application...")
-
-			wasPlaying = service.IsRadioPlaying()
-			restartTimer.Stop()
-			_ = stopApp(httpServer, mqttListener, service)
-			time.Sleep(appConfig.ErrorHandling.RecoveryDelay)
-		case err = <-errs:
-			if err == nil {
 				return
+			case <-restartTimer.C:
+				// The restart would end the recordings, it waits for them up to a
+				// limit, so a recording without a duration limit cannot prevent it.
+				if zones.All().IsRecording() {
+					if deferred < appRestartMaxRecordingWait {
+						log.Printf("Application restart deferred while recording, next check in %s", appRestartRecordingWait)
+						restartTimer.Reset(appRestartRecordingWait)
+						deferred += appRestartRecordingWait
+
+						continue
+					}
+
+					log.Printf("Application restart deferred for %s, stopping the recordings", deferred)
+				}
+
+				log.Println("Got restart signal")
+				log.Println("Stopping application...")
+
+				for _, zone := range zones.All() {
+					wasPlaying[zone.Name] = zone.Service.IsRadioPlaying()
+				}
+
+				restartTimer.Stop()
+				_ = stopApp(httpServer, mqttListener, zones)
+				time.Sleep(appConfig.ErrorHandling.RecoveryDelay)
+
+				restarted = true
+			case err = <-errs:
+				if err == nil {
+					return
+				}
+
+				log.Printf("Got runtime error: %v", err)
+				log.Fatalf("[ERROR] %v", err)
 			}
+		}
+	}
+}
 
-			log.Printf("Got runtime error: %v", err)
-			log.Fatalf("[ERROR] %v", err)
+func newRadioPlayer(appConfig *Config, zone string, streams []string, sink radio.Sink, errs chan<- error) *radio.Player {
+	radioPlayer := radio.NewPlayer(streams...)
+	radioPlayer.SetSink(sink)
+	radioPlayer.OnError(func(err error) {
+		// The output command is restarted by the sink.
+		if errors.Is(err, radio.ErrSinkCommand) {
+			log.Printf("[WARN] %v", err)
+
+			return
 		}
+
+		errs <- err
+	})
+	radioPlayer.SetReconnectPolicy(radio.ReconnectPolicy{
+		MaxAttempts:  appConfig.Reconnect.MaxAttempts,
+		InitialDelay: appConfig.Reconnect.InitialDelay,
+		MaxDelay:     appConfig.Reconnect.MaxDelay,
+		Multiplier:   appConfig.Reconnect.Multiplier,
+		Jitter:       appConfig.Reconnect.Jitter,
+	})
+	radioPlayer.SetWatchdogPolicy(radio.WatchdogPolicy{
+		StallTimeout:     appConfig.Watchdog.StallTimeout,
+		StallAction:      radio.WatchdogAction(appConfig.Watchdog.StallAction),
+		SilenceTimeout:   appConfig.Watchdog.SilenceTimeout,
+		SilenceThreshold: appConfig.Watchdog.SilenceThreshold,
+		SilenceAction:    radio.WatchdogAction(appConfig.Watchdog.SilenceAction),
+	})
+	radioPlayer.SetBufferPolicy(radio.BufferPolicy{
+		Size:         appConfig.Buffer.Size,
+		Prefill:      appConfig.Buffer.Prefill,
+		LowWatermark: appConfig.Buffer.LowWatermark,
+		TimeShift:    appConfig.Buffer.TimeShift,
+	})
+	radioPlayer.SetReplayPolicy(radio.ReplayPolicy{Duration: appConfig.Clip.Duration})
+	radioPlayer.SetCrossfade(appConfig.Crossfade.Duration)
+	radioPlayer.SetPowerFade(appConfig.Fade.Power)
+	radioPlayer.SetNormalization(radio.NormalizationPolicy{
+		Enabled:    appConfig.Normalization.Enabled,
+		Target:     appConfig.Normalization.Target,
+		MaxGain:    appConfig.Normalization.MaxGain,
+		Ceiling:    appConfig.Normalization.Ceiling,
+		Adaptation: appConfig.Normalization.Adaptation,
+	})
+	radioPlayer.SetCompressor(radio.CompressorPolicy{
+		Threshold:  appConfig.NightMode.Threshold,
+		Ratio:      appConfig.NightMode.Ratio,
+		Attack:     appConfig.NightMode.Attack,
+		Release:    appConfig.NightMode.Release,
+		MakeupGain: appConfig.NightMode.MakeupGain,
+		Ceiling:    appConfig.NightMode.Ceiling,
+	})
+	radioPlayer.OnStateChange(func(event radio.StateEvent) {
+		if zone != "" {
+			log.Printf("Radio state: %s (zone: %s, stream: %s, attempt: %d)", event.State, zone, event.Stream, event.Attempt)
+
+			return
+		}
+
+		log.Printf("Radio state: %s (stream: %s, attempt: %d)", event.State, event.Stream, event.Attempt)
+	})
+
+	return radioPlayer
+}
+
+func newService(
+	appConfig *Config,
+	configStorage streaming.ConfigStorage,
+	radioPlayer *radio.Player,
+	mqttListener *mqttapi.Listener,
+	zone string,
+) *streaming.Service {
+	service := streaming.NewService(configStorage, radioPlayer)
+	service.SetVolumeCurve(appConfig.VolumeCurve())
+	service.SetVolumeFade(appConfig.Fade.Volume)
+	service.SetClipPolicy(streaming.ClipPolicy{
+		Dir:       appConfig.Clip.Dir,
+		Duration:  appConfig.Clip.Duration,
+		Transcode: appConfig.Clip.Transcode,
+	})
+	service.SetRecordingPolicy(radio.RecordingPolicy{
+		Dir:         appConfig.Recording.Dir,
+		MaxDuration: appConfig.Recording.MaxDuration,
+		Quota:       appConfig.Recording.QuotaMB << 20,
+		SplitTracks: appConfig.Recording.SplitTracks,
+		Follow:      appConfig.Recording.Follow,
+	})
+	service.OnNowPlaying(mqttapi.NowPlayingPublisher(mqttListener, zoneTopic(appConfig.MQTTServer.NowPlayingTopic, zone)))
+	service.OnLevels(
+		mqttapi.LevelsPublisher(mqttListener, zoneTopic(appConfig.MQTTServer.LevelsTopic, zone)),
+		appConfig.MQTTServer.LevelsInterval,
+	)
+
+	return service
+}
+
+// zoneTopic is the topic of the state of a zone, the topic itself for the
+// single unnamed zone.
+func zoneTopic(topic, zone string) string {
+	if zone == "" {
+		return topic
 	}
+
+	return topic + "/" + zone
 }
 
 func runApp(
+	appConfig *Config,
 	httpServer *httpapi.Server,
 	mqttListener *mqttapi.Listener,
-	service *streaming.Service,
+	zones *streaming.Zones,
+	mqttGroup streaming.Group,
 	panicHandler func(v interface{}),
 ) error {
 	errs := make(chan error)
 
 	go func() {
-		errs <- runHTTPServer(httpServer, service, panicHandler)
+		errs <- runHTTPServer(httpServer, zones, appConfig.HTTPServer.VolumeStep, appConfig.Clip.After, panicHandler)
 	}()
 
 	go func() {
-		errs <- runMQTTServer(mqttListener, service, panicHandler)
+		errs <- runMQTTServer(mqttListener, mqttGroup, appConfig.MQTTServer.VolumeStep, appConfig.Clip.After, panicHandler)
 	}()
 
 	return <-errs
 }
 
-func stopApp(httpServer *httpapi.Server, mqttListener *mqttapi.Listener, service *streaming.Service) []error {
+func stopApp(httpServer *httpapi.Server, mqttListener *mqttapi.Listener, zones *streaming.Zones) []error {
 	var errs []error
 
 	err := httpServer.Close()
@@ -133,7 +326,7 @@ func stopApp(httpServer *httpapi.Server, mqttListener *mqttapi.Listener, service
 		errs = append(errs, err)
 	}
 
-	err = service.Close()
+	err = zones.Close()
 	if err != nil {
 		errs = append(errs, err)
 	}
@@ -141,30 +334,107 @@ func stopApp(httpServer *httpapi.Server, mqttListener *mqttapi.Listener, service
 	return errs
 }
 
+// closeSinks finishes the outputs that need it, like the WAV files.
+func closeSinks(sinks map[string]radio.Sink) {
+	for name, sink := range sinks {
+		closer, ok := sink.(io.Closer)
+		if !ok {
+			continue
+		}
+
+		err := closer.Close()
+		if err != nil {
+			log.Printf("[WARN] Cannot close the output (zone: %s): %v", name, err)
+		}
+	}
+}
+
 func runHTTPServer(
 	httpServer *httpapi.Server,
-	service *streaming.Service,
+	zones *streaming.Zones,
+	volumeStep int,
+	clipAfter time.Duration,
 	panicHandler func(v interface{}),
 ) error {
 	httpServer.
 		Register("/radio/power", httpapi.WrapHandler(
-			httpapi.RadioPowerHandler(service),
+			httpapi.RadioPowerHandler(zones),
 			httpapi.RecoverMiddleware(panicHandler),
 		)).
 		Register("/radio/stream/prev", httpapi.WrapHandler(
-			httpapi.RadioStreamPrevHandler(service),
+			httpapi.ZonesHandler(zones, httpapi.RadioStreamPrevHandler),
 			httpapi.RecoverMiddleware(panicHandler),
 		)).
 		Register("/radio/stream/next", httpapi.WrapHandler(
-			httpapi.RadioStreamNextHandler(service),
+			httpapi.ZonesHandler(zones, httpapi.RadioStreamNextHandler),
 			httpapi.RecoverMiddleware(panicHandler),
 		)).
 		Register("/radio/volume/up", httpapi.WrapHandler(
-			httpapi.VolumeUpHandler(service),
+			httpapi.ZonesHandler(zones, func(service *streaming.Service) http.HandlerFunc {
+				return httpapi.VolumeUpHandler(service, volumeStep)
+			}),
 			httpapi.RecoverMiddleware(panicHandler),
 		)).
 		Register("/radio/volume/down", httpapi.WrapHandler(
-			httpapi.VolumeDownHandler(service),
+			httpapi.ZonesHandler(zones, func(service *streaming.Service) http.HandlerFunc {
+				return httpapi.VolumeDownHandler(service, volumeStep)
+			}),
+			httpapi.RecoverMiddleware(panicHandler),
+		)).
+		Register("/radio/now-playing", httpapi.WrapHandler(
+			httpapi.ZonesHandler(zones, httpapi.RadioNowPlayingHandler),
+			httpapi.RecoverMiddleware(panicHandler),
+		)).
+		Register("/radio/buffer", httpapi.WrapHandler(
+			httpapi.ZonesHandler(zones, httpapi.RadioBufferHandler),
+			httpapi.RecoverMiddleware(panicHandler),
+		)).
+		Register("/radio/levels", httpapi.WrapHandler(
+			httpapi.ZonesHandler(zones, httpapi.RadioLevelsHandler),
+			httpapi.RecoverMiddleware(panicHandler),
+		)).
+		Register("/radio/filters", httpapi.WrapHandler(
+			httpapi.ZonesHandler(zones, httpapi.RadioFiltersHandler),
+			httpapi.RecoverMiddleware(panicHandler),
+		)).
+		Register("/radio/filters/adjust", httpapi.WrapHandler(
+			httpapi.ZonesHandler(zones, httpapi.RadioFilterAdjustHandler),
+			httpapi.RecoverMiddleware(panicHandler),
+		)).
+		Register("/radio/night-mode", httpapi.WrapHandler(
+			httpapi.ZonesHandler(zones, httpapi.RadioNightModeHandler),
+			httpapi.RecoverMiddleware(panicHandler),
+		)).
+		Register("/radio/pause", httpapi.WrapHandler(
+			httpapi.ZonesHandler(zones, httpapi.RadioPauseHandler),
+			httpapi.RecoverMiddleware(panicHandler),
+		)).
+		Register("/radio/live", httpapi.WrapHandler(
+			httpapi.ZonesHandler(zones, httpapi.RadioLiveHandler),
+			httpapi.RecoverMiddleware(panicHandler),
+		)).
+		Register("/radio/record", httpapi.WrapHandler(
+			httpapi.ZonesHandler(zones, httpapi.RadioRecordHandler),
+			httpapi.RecoverMiddleware(panicHandler),
+		)).
+		Register("/radio/recording", httpapi.WrapHandler(
+			httpapi.ZonesHandler(zones, httpapi.RadioRecordingHandler),
+			httpapi.RecoverMiddleware(panicHandler),
+		)).
+		Register("/radio/clip", httpapi.WrapHandler(
+			httpapi.RadioClipHandler(zones, clipAfter),
+			httpapi.RecoverMiddleware(panicHandler),
+		)).
+		Register("/radio/clips", httpapi.WrapHandler(
+			httpapi.ZoneHandler(zones, httpapi.RadioClipsHandler),
+			httpapi.RecoverMiddleware(panicHandler),
+		)).
+		Register("/radio/clips/", httpapi.WrapHandler(
+			httpapi.ZoneHandler(zones, httpapi.RadioClipsHandler),
+			httpapi.RecoverMiddleware(panicHandler),
+		)).
+		Register("/radio/listen", httpapi.WrapHandler(
+			httpapi.ZoneHandler(zones, httpapi.RadioListenHandler),
 			httpapi.RecoverMiddleware(panicHandler),
 		))
 
@@ -173,34 +443,139 @@ func runHTTPServer(
 
 func runMQTTServer(
 	mqttListener *mqttapi.Listener,
-	service *streaming.Service,
+	group streaming.Group,
+	volumeStep int,
+	clipAfter time.Duration,
 	panicHandler func(v interface{}),
 ) error {
 	mqttListener.
 		Register("button_1_click", mqttapi.WrapHandler(
-			mqttapi.RadioPowerHandler(service),
+			mqttapi.RadioPowerHandler(group),
 			mqttapi.RecoverMiddleware(panicHandler),
 		)).
 		Register("button_2_click", mqttapi.WrapHandler(
-			mqttapi.RadioStreamNextHandler(service),
+			mqttapi.ZonesHandler(group, mqttapi.RadioStreamNextHandler),
 			mqttapi.RecoverMiddleware(panicHandler),
 		)).
 		Register("button_2_hold", mqttapi.WrapHandler(
-			mqttapi.RadioStreamPrevHandler(service),
+			mqttapi.ZonesHandler(group, mqttapi.RadioStreamPrevHandler),
 			mqttapi.RecoverMiddleware(panicHandler),
 		)).
 		Register("button_3_click", mqttapi.WrapHandler(
-			mqttapi.VolumeDownHandler(service),
+			mqttapi.ZonesHandler(group, func(service *streaming.Service) mqttapi.Handler {
+				return mqttapi.VolumeDownHandler(service, volumeStep)
+			}),
 			mqttapi.RecoverMiddleware(panicHandler),
 		)).
 		Register("button_4_click", mqttapi.WrapHandler(
-			mqttapi.VolumeUpHandler(service),
+			mqttapi.ZonesHandler(group, func(service *streaming.Service) mqttapi.Handler {
+				return mqttapi.VolumeUpHandler(service, volumeStep)
+			}),
+			mqttapi.RecoverMiddleware(panicHandler),
+		)).
+		Register("button_1_hold", mqttapi.WrapHandler(
+			mqttapi.ZonesHandler(group, mqttapi.RadioPauseHandler),
+			mqttapi.RecoverMiddleware(panicHandler),
+		)).
+		Register("button_4_hold", mqttapi.WrapHandler(
+			mqttapi.ZonesHandler(group, mqttapi.RadioLiveHandler),
+			mqttapi.RecoverMiddleware(panicHandler),
+		)).
+		Register("button_3_hold", mqttapi.WrapHandler(
+			mqttapi.RadioClipHandler(group, clipAfter),
 			mqttapi.RecoverMiddleware(panicHandler),
 		))
 
 	return mqttListener.Listen()
 }
 
+func newSink(appConfig *Config, output ZoneOutput) (radio.Sink, error) {
+	switch output.Sink {
+	case "oto":
+		return radio.NewOtoSink(), nil
+	case "null":
+		return radio.NewNullSink(), nil
+	case "wav":
+		path := appConfig.Output.WAVPath
+		if output.Arg != "" {
+			path = output.Arg
+		}
+
+		return radio.NewWAVSink(path)
+	case "memory":
+		return radio.NewMemorySink(), nil
+	case "command":
+		command := appConfig.Output.Command
+		if output.Arg != "" {
+			command = output.Arg
+		}
+
+		return radio.NewCommandSink(command), nil
+	default:
+		return nil, fmt.Errorf("unknown output sink: %s", output.Sink)
+	}
+}
+
+// startSyncLeader makes the zone of SYNC_ZONE, the first one by default, the
+// leader of the group, playing to its sink.
+func startSyncLeader(appConfig *Config, zoneOutputs []ZoneOutput, sinks map[string]radio.Sink) error {
+	zone := zoneOutputs[0].Name
+	if appConfig.Sync.Zone != "" {
+		zone = appConfig.Sync.Zone
+	}
+
+	sink, ok := sinks[zone]
+	if !ok {
+		return fmt.Errorf("%w: %s", streaming.ErrZoneNotFound, zone)
+	}
+
+	leader, err := radio.NewSyncLeader(sink, appConfig.Sync.Address, radio.SyncPolicy{
+		Delay:   appConfig.Sync.Delay,
+		Latency: appConfig.Sync.Latency,
+	})
+	if err != nil {
+		return err
+	}
+
+	sinks[zone] = leader
+
+	log.Printf("Sync leader listening (address: %s)", appConfig.Sync.Address)
+
+	return nil
+}
+
+// runSyncFollower plays the audio of the leader at SYNC_ADDRESS to
+// OUTPUT_SINK, until the termination signal.
+func runSyncFollower(appConfig *Config, signals <-chan os.Signal) {
+	sink, err := newSink(appConfig, ZoneOutput{Sink: appConfig.Output.Sink})
+	if err != nil {
+		log.Fatalf("[ERROR] %v", err)
+	}
+
+	follower := radio.NewSyncFollower(appConfig.Sync.Address, sink, radio.SyncPolicy{Latency: appConfig.Sync.Latency})
+	follower.SetReconnectPolicy(radio.ReconnectPolicy{
+		InitialDelay: appConfig.Reconnect.InitialDelay,
+		MaxDelay:     appConfig.Reconnect.MaxDelay,
+		Multiplier:   appConfig.Reconnect.Multiplier,
+		Jitter:       appConfig.Reconnect.Jitter,
+	})
+
+	go func() {
+		<-signals
+		log.Println("Got termination signal")
+		log.Println("Stopping application...")
+
+		_ = follower.Close()
+	}()
+
+	log.Printf("Following sync leader (address: %s)", appConfig.Sync.Address)
+
+	err = follower.Run()
+	if err != nil {
+		log.Fatalf("[ERROR] %v", err)
+	}
+}
+
 func configFilePath() string {
 	ex, _ := os.Executable()
 
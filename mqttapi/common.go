@@ -1,5 +0,0 @@
-package mqttapi
-
-const (
-	volumeStep = 0.1
-)
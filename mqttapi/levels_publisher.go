@@ -0,0 +1,24 @@
+package mqttapi
+
+import (
+	"encoding/json"
+	"log"
+
+	"github.com/kpeu3i/radio-streamer/streaming"
+)
+
+func LevelsPublisher(listener *Listener, topic string) streaming.LevelsHandler {
+	return func(levels streaming.Levels) {
+		payload, err := json.Marshal(levels)
+		if err != nil {
+			log.Printf("[ERROR] %v\n", err)
+
+			return
+		}
+
+		err = listener.Publish(topic, payload)
+		if err != nil {
+			log.Printf("[ERROR] %v\n", err)
+		}
+	}
+}
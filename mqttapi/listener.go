@@ -44,6 +44,18 @@ func (l *Listener) Register(action string, handler Handler) *Listener {
 	return l
 }
 
+// Publish sends a retained message, so subscribers get the latest state right away.
+func (l *Listener) Publish(topic string, payload []byte) error {
+	if !l.client.IsConnected() {
+		return nil
+	}
+
+	token := l.client.Publish(topic, 0, true, payload)
+	token.Wait()
+
+	return token.Error()
+}
+
 func (l *Listener) Close() error {
 	if !l.client.IsConnected() {
 		return nil
@@ -0,0 +1,24 @@
+package mqttapi
+
+import (
+	"encoding/json"
+	"log"
+
+	"github.com/kpeu3i/radio-streamer/streaming"
+)
+
+func NowPlayingPublisher(listener *Listener, topic string) streaming.NowPlayingHandler {
+	return func(nowPlaying streaming.NowPlaying) {
+		payload, err := json.Marshal(nowPlaying)
+		if err != nil {
+			log.Printf("[ERROR] %v\n", err)
+
+			return
+		}
+
+		err = listener.Publish(topic, payload)
+		if err != nil {
+			log.Printf("[ERROR] %v\n", err)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package mqttapi
+
+import (
+	"log"
+	"time"
+
+	"github.com/kpeu3i/radio-streamer/streaming"
+)
+
+// RadioClipHandler saves the clips of the zones of the group in the
+// background, since it may wait for the audio after the button press.
+func RadioClipHandler(group streaming.Group, after time.Duration) Handler {
+	return func() {
+		go func() {
+			clips, err := group.SaveClip(after)
+
+			for _, clip := range clips {
+				log.Printf("Clip saved: %s\n", clip.Name)
+			}
+
+			if err != nil {
+				logZoneErrors(group, err)
+			}
+		}()
+	}
+}
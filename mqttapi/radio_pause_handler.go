@@ -0,0 +1,16 @@
+package mqttapi
+
+import (
+	"log"
+
+	"github.com/kpeu3i/radio-streamer/streaming"
+)
+
+func RadioPauseHandler(service *streaming.Service) Handler {
+	return func() {
+		err := service.TogglePause()
+		if err != nil {
+			log.Printf("[ERROR] %v\n", err)
+		}
+	}
+}
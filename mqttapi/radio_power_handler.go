@@ -1,20 +1,15 @@
 package mqttapi
 
 import (
-	"log"
-
 	"github.com/kpeu3i/radio-streamer/streaming"
 )
 
-func RadioPowerHandler(service *streaming.Service) Handler {
+// RadioPowerHandler toggles the power of the zones of the group together.
+func RadioPowerHandler(group streaming.Group) Handler {
 	return func() {
-		if service.IsRadioPlaying() {
-			service.StopRadio()
-		} else {
-			err := service.PlayRadio()
-			if err != nil {
-				log.Printf("[ERROR] %v\n", err)
-			}
+		err := group.TogglePower()
+		if err != nil {
+			logZoneErrors(group, err)
 		}
 	}
 }
@@ -6,9 +6,9 @@ import (
 	"github.com/kpeu3i/radio-streamer/streaming"
 )
 
-func VolumeDownHandler(service *streaming.Service) Handler {
+func VolumeDownHandler(service *streaming.Service, steps int) Handler {
 	return func() {
-		err := service.DownVolume(volumeStep)
+		err := service.DownVolume(steps)
 		if err != nil {
 			log.Printf("[ERROR] %v\n", err)
 		}
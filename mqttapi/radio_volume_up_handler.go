@@ -6,9 +6,9 @@ import (
 	"github.com/kpeu3i/radio-streamer/streaming"
 )
 
-func VolumeUpHandler(service *streaming.Service) Handler {
+func VolumeUpHandler(service *streaming.Service, steps int) Handler {
 	return func() {
-		err := service.UpVolume(volumeStep)
+		err := service.UpVolume(steps)
 		if err != nil {
 			log.Printf("[ERROR] %v\n", err)
 		}
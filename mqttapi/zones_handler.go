@@ -0,0 +1,41 @@
+package mqttapi
+
+import (
+	"errors"
+	"log"
+
+	"github.com/kpeu3i/radio-streamer/streaming"
+)
+
+// ServiceHandler makes the handler of the service of a zone.
+type ServiceHandler func(service *streaming.Service) Handler
+
+// ZonesHandler runs the handler on each zone of the group.
+func ZonesHandler(group streaming.Group, handler ServiceHandler) Handler {
+	handlers := make([]Handler, len(group))
+	for i, zone := range group {
+		handlers[i] = handler(zone.Service)
+	}
+
+	return func() {
+		for _, h := range handlers {
+			h()
+		}
+	}
+}
+
+// logZoneErrors logs the error of each zone of the group that failed.
+func logZoneErrors(group streaming.Group, err error) {
+	var errs streaming.ZoneErrors
+	if !errors.As(err, &errs) {
+		log.Printf("[ERROR] %v\n", err)
+
+		return
+	}
+
+	for _, zone := range group {
+		if err, ok := errs[zone.Name]; ok {
+			log.Printf("[ERROR] zone %s: %v\n", zone.Name, err)
+		}
+	}
+}
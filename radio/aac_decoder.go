@@ -0,0 +1,256 @@
+package radio
+
+/*
+#cgo LDFLAGS: -lfaad -lm
+
+#include <neaacdec.h>
+*/
+import "C"
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"unsafe"
+)
+
+const (
+	adtsMaxFrameLength  = 1<<13 - 1
+	aacSamplesPerFrame  = 1024
+	aacMaxFrameErrors   = 16
+	aacMaxPrimingFrames = 8
+)
+
+var adtsSampleRates = [...]int{96000, 88200, 64000, 48000, 44100, 32000, 24000, 22050, 16000, 12000, 11025, 8000, 7350}
+
+// aacDecoder decodes AAC-LC and HE-AAC (v1/v2) ADTS streams with libfaad2.
+type aacDecoder struct {
+	reader     *bufio.Reader
+	handle     C.NeAACDecHandle
+	pcm        []byte
+	sampleRate int
+	channels   int
+	coreRate   int
+	bytes      int
+	frames     int
+	errors     int
+	mu         sync.Mutex
+}
+
+func AACFormat() DecoderFormat {
+	return DecoderFormat{
+		Name:         "aac",
+		ContentTypes: []string{"audio/aac", "audio/aacp", "audio/x-aac", "audio/aac-adts", "audio/x-hx-aac-adts"},
+		Sniff:        sniffADTS,
+		New:          newAACDecoder,
+	}
+}
+
+func newAACDecoder(r io.Reader) (Decoder, error) {
+	d := &aacDecoder{reader: bufio.NewReaderSize(r, adtsMaxFrameLength)}
+
+	frame, err := d.readFrame()
+	if err != nil {
+		return nil, err
+	}
+
+	d.handle = C.NeAACDecOpen()
+	if d.handle == nil {
+		return nil, errors.New("cannot open aac decoder")
+	}
+
+	config := C.NeAACDecGetCurrentConfiguration(d.handle)
+	config.outputFormat = C.FAAD_FMT_16BIT
+	config.downMatrix = 1
+	C.NeAACDecSetConfiguration(d.handle, config)
+
+	var sampleRate C.ulong
+	var channels C.uchar
+
+	if C.NeAACDecInit(d.handle, (*C.uchar)(unsafe.Pointer(&frame[0])), C.ulong(len(frame)), &sampleRate, &channels) < 0 {
+		_ = d.Close()
+
+		return nil, errors.New("cannot start decoding")
+	}
+
+	d.sampleRate = int(sampleRate)
+	d.channels = int(channels)
+
+	// The actual output format is only known after the first decoded frame,
+	// since SBR and PS may double the sample rate and the number of channels.
+	for i := 0; ; i++ {
+		err = d.decode(frame)
+		if err != nil {
+			_ = d.Close()
+
+			return nil, err
+		}
+
+		if len(d.pcm) > 0 {
+			break
+		}
+
+		if i >= aacMaxPrimingFrames {
+			_ = d.Close()
+
+			return nil, errors.New("cannot start decoding")
+		}
+
+		frame, err = d.readFrame()
+		if err != nil {
+			_ = d.Close()
+
+			return nil, err
+		}
+	}
+
+	return d, nil
+}
+
+func (d *aacDecoder) Read(p []byte) (int, error) {
+	for len(d.pcm) == 0 {
+		frame, err := d.readFrame()
+		if err != nil {
+			return 0, err
+		}
+
+		err = d.decode(frame)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, d.pcm)
+	d.pcm = d.pcm[n:]
+
+	return n, nil
+}
+
+func (d *aacDecoder) SampleRate() int {
+	return d.sampleRate
+}
+
+func (d *aacDecoder) Channels() int {
+	return d.channels
+}
+
+func (d *aacDecoder) Bitrate() int {
+	if d.frames == 0 {
+		return 0
+	}
+
+	return d.bytes * 8 * d.coreRate / (d.frames * aacSamplesPerFrame * 1000)
+}
+
+func (d *aacDecoder) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.handle != nil {
+		C.NeAACDecClose(d.handle)
+		d.handle = nil
+	}
+
+	return nil
+}
+
+func (d *aacDecoder) decode(frame []byte) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.handle == nil {
+		return io.ErrClosedPipe
+	}
+
+	var info C.NeAACDecFrameInfo
+
+	samples := C.NeAACDecDecode(d.handle, &info, (*C.uchar)(unsafe.Pointer(&frame[0])), C.ulong(len(frame)))
+	if info.error != 0 {
+		d.errors++
+		if d.errors > aacMaxFrameErrors {
+			return fmt.Errorf("aac: %s", C.GoString(C.NeAACDecGetErrorMessage(info.error)))
+		}
+
+		return nil
+	}
+
+	d.errors = 0
+	d.bytes += len(frame)
+	d.frames++
+
+	if samples == nil || info.samples == 0 {
+		return nil
+	}
+
+	d.sampleRate = int(info.samplerate)
+	d.channels = int(info.channels)
+	d.pcm = C.GoBytes(samples, C.int(info.samples*2))
+
+	return nil
+}
+
+func (d *aacDecoder) readFrame() ([]byte, error) {
+	for {
+		header, err := d.reader.Peek(7)
+		if err != nil {
+			return nil, err
+		}
+
+		length, ok := adtsFrameLength(header)
+		if !ok {
+			_, _ = d.reader.Discard(1)
+
+			continue
+		}
+
+		frame := make([]byte, length)
+
+		_, err = io.ReadFull(d.reader, frame)
+		if err != nil {
+			return nil, err
+		}
+
+		d.coreRate = adtsSampleRates[(header[2]>>2)&0x0F]
+
+		return frame, nil
+	}
+}
+
+func sniffADTS(header []byte) bool {
+	for i := 0; i+7 <= len(header); i++ {
+		length, ok := adtsFrameLength(header[i:])
+		if !ok || i+length+7 > len(header) {
+			continue
+		}
+
+		if _, ok := adtsFrameLength(header[i+length:]); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func adtsFrameLength(header []byte) (int, bool) {
+	if len(header) < 7 || header[0] != 0xFF || header[1]&0xF6 != 0xF0 {
+		return 0, false
+	}
+
+	if int(header[2]>>2)&0x0F >= len(adtsSampleRates) {
+		return 0, false
+	}
+
+	headerLength := 7
+	if header[1]&0x01 == 0 {
+		headerLength = 9
+	}
+
+	length := int(header[3]&0x03)<<11 | int(header[4])<<3 | int(header[5])>>5
+	if length <= headerLength {
+		return 0, false
+	}
+
+	return length, true
+}
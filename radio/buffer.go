@@ -0,0 +1,429 @@
+package radio
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	bufferFillChunkSize = 16 << 10
+
+	// timeShiftFade is the fade at the pause and resume points.
+	timeShiftFade = 100 * time.Millisecond
+)
+
+var ErrNoTimeShift = errors.New("time shift disabled")
+
+// BufferPolicy controls the buffer of decoded audio between the stream and
+// the output. Playback starts once Prefill is buffered, and pauses to
+// rebuffer up to Prefill when the level drops below LowWatermark or the
+// buffer runs dry. A zero Size disables buffering. TimeShift is how much
+// live audio is kept while paused, the buffer grows up to it when needed.
+type BufferPolicy struct {
+	Size         time.Duration
+	Prefill      time.Duration
+	LowWatermark time.Duration
+	TimeShift    time.Duration
+}
+
+func DefaultBufferPolicy() BufferPolicy {
+	return BufferPolicy{
+		Size:    10 * time.Second,
+		Prefill: 2 * time.Second,
+	}
+}
+
+// BufferStats describe the buffer. Behind is how far the playback is behind
+// the live stream, after a pause.
+type BufferStats struct {
+	Size      time.Duration
+	Level     time.Duration
+	Buffering bool
+	Underruns int
+	Paused    bool
+	Behind    time.Duration
+}
+
+// jitterBuffer is a ring buffer of PCM frames filled from the source by its
+// own goroutine, so network jitter does not reach the output. While
+// buffering it plays silence instead of blocking the output.
+//
+// While paused it keeps filling and plays silence, the audio kept ahead of
+// the output grows by the paused time, up to the time shift. The oldest audio
+// is dropped past it, so the stream is still read live.
+type jitterBuffer struct {
+	reader       io.Reader
+	data         []byte
+	start        int
+	length       int
+	size         int
+	capacity     int
+	behind       int
+	paused       bool
+	silent       bool
+	live         bool
+	gain         float64
+	fadeStep     float64
+	frameSize    int
+	bytesPerSec  int
+	prefill      int
+	lowWatermark int
+	buffering    bool
+	underruns    int
+	err          error
+	closed       bool
+	ready        chan struct{}
+	readyOnce    sync.Once
+	cond         *sync.Cond
+	mu           sync.Mutex
+}
+
+func newJitterBuffer(reader io.Reader, policy BufferPolicy, sampleRate, channels int) *jitterBuffer {
+	frameSize := 2 * channels
+	bytesPerSec := sampleRate * frameSize
+
+	size := frames(policy.Size, bytesPerSec, frameSize)
+	if size < frameSize {
+		size = frameSize
+	}
+
+	prefill := frames(policy.Prefill, bytesPerSec, frameSize)
+	if prefill > size {
+		prefill = size
+	}
+
+	lowWatermark := frames(policy.LowWatermark, bytesPerSec, frameSize)
+	if lowWatermark > prefill {
+		lowWatermark = prefill
+	}
+
+	b := &jitterBuffer{
+		reader:       reader,
+		data:         make([]byte, size),
+		size:         size,
+		capacity:     size + frames(policy.TimeShift, bytesPerSec, frameSize),
+		gain:         1,
+		fadeStep:     1 / (timeShiftFade.Seconds() * float64(sampleRate)),
+		frameSize:    frameSize,
+		bytesPerSec:  bytesPerSec,
+		prefill:      prefill,
+		lowWatermark: lowWatermark,
+		buffering:    prefill > 0,
+		ready:        make(chan struct{}),
+	}
+	b.cond = sync.NewCond(&b.mu)
+
+	if !b.buffering {
+		b.setReady()
+	}
+
+	go b.fill()
+
+	return b
+}
+
+func frames(d time.Duration, bytesPerSec, frameSize int) int {
+	n := int(d.Seconds() * float64(bytesPerSec))
+
+	return n - n%frameSize
+}
+
+func (b *jitterBuffer) Read(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.silent {
+		if b.live {
+			b.drop(b.behind)
+			b.behind, b.live = 0, false
+		}
+
+		if b.paused {
+			n := b.silence(p)
+			b.behind += n
+			if b.behind > b.capacity-b.size {
+				b.behind = b.capacity - b.size
+			}
+
+			return n, nil
+		}
+
+		b.silent = false
+		b.cond.Signal()
+	}
+
+	if b.err == nil && !b.buffering && (b.length < b.frameSize || b.length < b.lowWatermark) {
+		b.buffering = true
+		b.underruns++
+
+		log.Printf("Buffer underrun, rebuffering (underruns: %d)\n", b.underruns)
+	}
+
+	if b.buffering {
+		return b.silence(p), nil
+	}
+
+	n := len(p)
+	if n > b.length {
+		n = b.length
+	}
+
+	if b.err == nil {
+		n -= n % b.frameSize
+	}
+
+	if n == 0 {
+		return 0, b.err
+	}
+
+	end := b.start + n
+	if end <= len(b.data) {
+		copy(p, b.data[b.start:end])
+	} else {
+		copied := copy(p, b.data[b.start:])
+		copy(p[copied:n], b.data)
+	}
+
+	b.start = (b.start + n) % len(b.data)
+	b.length -= n
+	b.cond.Signal()
+
+	b.fade(p[:n])
+
+	return n, nil
+}
+
+// fade ramps the gain towards silence while pausing or jumping to live, and
+// back up after. Once silent, the rest of the frames is put back, so the
+// playback resumes right there.
+func (b *jitterBuffer) fade(p []byte) {
+	target := 1.0
+	if b.paused || b.live {
+		target = 0
+	}
+
+	if b.gain == target {
+		return
+	}
+
+	for i := 0; i+b.frameSize <= len(p); i += b.frameSize {
+		if b.gain < target {
+			b.gain += b.fadeStep
+			if b.gain > target {
+				b.gain = target
+			}
+		} else {
+			b.gain -= b.fadeStep
+			if b.gain < target {
+				b.gain = target
+			}
+		}
+
+		for j := i; j < i+b.frameSize; j += 2 {
+			sample := float64(int16(binary.LittleEndian.Uint16(p[j:]))) * b.gain
+			binary.LittleEndian.PutUint16(p[j:], uint16(int16(sample)))
+		}
+
+		if b.gain == 0 {
+			rest := len(p) - i - b.frameSize
+			for j := range p[i+b.frameSize:] {
+				p[i+b.frameSize+j] = 0
+			}
+
+			b.start = (b.start - rest + len(b.data)) % len(b.data)
+			b.length += rest
+			b.silent = true
+
+			return
+		}
+	}
+}
+
+func (b *jitterBuffer) silence(p []byte) int {
+	n := len(p) - len(p)%b.frameSize
+	for i := range p[:n] {
+		p[i] = 0
+	}
+
+	return n
+}
+
+// drop discards up to n bytes of the oldest audio.
+func (b *jitterBuffer) drop(n int) {
+	if n > b.length {
+		n = b.length
+	}
+
+	n -= n % b.frameSize
+
+	b.start = (b.start + n) % len(b.data)
+	b.length -= n
+	b.cond.Signal()
+}
+
+// Pause fades out and plays silence, keeping the stream.
+func (b *jitterBuffer) Pause() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.paused = true
+	b.cond.Signal()
+}
+
+func (b *jitterBuffer) Resume() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.paused = false
+}
+
+// GoLive drops the audio kept by the pauses and resumes.
+func (b *jitterBuffer) GoLive() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.paused = false
+	if b.behind > 0 {
+		b.live = true
+	}
+}
+
+func (b *jitterBuffer) Stats() BufferStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return BufferStats{
+		Size:      b.duration(b.size),
+		Level:     b.duration(b.length),
+		Buffering: b.buffering,
+		Underruns: b.underruns,
+		Paused:    b.paused,
+		Behind:    b.duration(b.behind),
+	}
+}
+
+func (b *jitterBuffer) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	b.cond.Broadcast()
+	b.setReady()
+}
+
+// Ready is closed once the buffer is prefilled for the first time, or the
+// source has failed before.
+func (b *jitterBuffer) Ready() <-chan struct{} {
+	return b.ready
+}
+
+func (b *jitterBuffer) setReady() {
+	b.readyOnce.Do(func() {
+		close(b.ready)
+	})
+}
+
+func (b *jitterBuffer) duration(n int) time.Duration {
+	return time.Duration(n) * time.Second / time.Duration(b.bytesPerSec)
+}
+
+func (b *jitterBuffer) fill() {
+	chunk := make([]byte, bufferFillChunkSize)
+
+	for {
+		b.mu.Lock()
+		for b.length >= b.limit() && !b.paused && !b.closed {
+			b.cond.Wait()
+		}
+
+		space := len(chunk)
+		if !b.paused && b.limit()-b.length < space {
+			space = b.limit() - b.length
+		}
+
+		closed := b.closed
+		b.mu.Unlock()
+
+		if closed {
+			return
+		}
+
+		n, err := b.reader.Read(chunk[:space])
+
+		b.mu.Lock()
+		b.write(chunk[:n])
+
+		if err != nil {
+			b.err = err
+		}
+
+		if b.buffering && (b.length >= b.prefill || b.err != nil) {
+			b.buffering = false
+			b.setReady()
+		}
+		b.mu.Unlock()
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+// limit is how much audio is kept: the buffer size, plus how far the
+// playback is behind after the pauses.
+func (b *jitterBuffer) limit() int {
+	limit := b.size + b.behind
+	if limit > b.capacity {
+		limit = b.capacity
+	}
+
+	return limit
+}
+
+// write grows the ring up to the capacity, then drops the oldest audio. Data
+// larger than the ring replaces all of it with its end.
+func (b *jitterBuffer) write(p []byte) {
+	if b.length+len(p) > len(b.data) && len(b.data) < b.capacity {
+		b.grow(b.length + len(p))
+	}
+
+	if len(p) >= len(b.data) {
+		p = p[len(p)-len(b.data):]
+		b.start, b.length = 0, 0
+	}
+
+	if overflow := b.length + len(p) - len(b.data); overflow > 0 {
+		b.start = (b.start + overflow) % len(b.data)
+		b.length -= overflow
+	}
+
+	end := (b.start + b.length) % len(b.data)
+
+	copied := copy(b.data[end:], p)
+	copy(b.data, p[copied:])
+
+	b.length += len(p)
+}
+
+func (b *jitterBuffer) grow(size int) {
+	if size < 2*len(b.data) {
+		size = 2 * len(b.data)
+	}
+
+	if size > b.capacity {
+		size = b.capacity
+	}
+
+	data := make([]byte, size)
+
+	copied := copy(data, b.data[b.start:])
+	if copied < b.length {
+		copy(data[copied:], b.data[:b.length-copied])
+	}
+
+	b.data, b.start = data, 0
+}
@@ -0,0 +1,28 @@
+package radio
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestJitterBufferWriteLargerThanRing(t *testing.T) {
+	b := &jitterBuffer{data: make([]byte, 8), capacity: 8}
+
+	b.write([]byte{1, 2, 3})
+
+	p := make([]byte, 20)
+	for i := range p {
+		p[i] = byte(10 + i)
+	}
+
+	b.write(p)
+
+	if b.length != len(b.data) {
+		t.Fatalf("got length %d, want %d", b.length, len(b.data))
+	}
+
+	got := append(append([]byte(nil), b.data[b.start:]...), b.data[:b.start]...)
+	if want := p[len(p)-8:]; !bytes.Equal(got, want) {
+		t.Fatalf("got %v, want the end of the data %v", got, want)
+	}
+}
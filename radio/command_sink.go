@@ -0,0 +1,203 @@
+package radio
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	commandRestartDelay = time.Second
+)
+
+var ErrSinkCommand = errors.New("output command failed")
+
+// commandSink pipes the mixed PCM to the stdin of a shell command, such as
+// "aplay -D hw:1,0 -f S16_LE -c 2 -r 44100". The command is started when the
+// audio starts, restarted if it exits and stopped when the sink is suspended.
+// The pipe blocks while the command is playing, which paces the mixing.
+type commandSink struct {
+	*mixerSink
+	command *pcmCommand
+}
+
+func NewCommandSink(command string) Sink {
+	c := &pcmCommand{command: command}
+
+	sink := newMixerSink(c)
+	sink.blocking = true
+	sink.onSuspend = c.stop
+
+	return &commandSink{mixerSink: sink, command: c}
+}
+
+// Close stops the command first, so the mixer is not left blocked on it.
+func (s *commandSink) Close() error {
+	s.command.close()
+
+	return s.mixerSink.Close()
+}
+
+// Err reports the failures of the command once, since it is restarted.
+func (s *commandSink) Err() error {
+	err := s.mixerSink.Err()
+	if err != nil {
+		return err
+	}
+
+	return s.command.takeErr()
+}
+
+type pcmCommand struct {
+	command   string
+	cmd       *exec.Cmd
+	stdin     io.WriteCloser
+	restartAt time.Time
+	closed    bool
+	err       error
+	mu        sync.Mutex
+}
+
+// Write never fails, the audio is dropped while the command is restarting, at
+// the pace it would have been played. The pipe is written without the mutex,
+// so a stuck command can be killed.
+func (c *pcmCommand) Write(p []byte) (int, error) {
+	c.mu.Lock()
+
+	if c.closed {
+		c.mu.Unlock()
+
+		return len(p), nil
+	}
+
+	if c.cmd == nil {
+		if time.Now().Before(c.restartAt) {
+			c.mu.Unlock()
+			time.Sleep(pcmDuration(len(p)))
+
+			return len(p), nil
+		}
+
+		err := c.start()
+		if err != nil {
+			c.fail(err)
+			c.mu.Unlock()
+			time.Sleep(pcmDuration(len(p)))
+
+			return len(p), nil
+		}
+	}
+
+	cmd, stdin := c.cmd, c.stdin
+	c.mu.Unlock()
+
+	_, err := stdin.Write(p)
+	if err != nil {
+		c.mu.Lock()
+		if c.cmd == cmd {
+			c.fail(err)
+			c.kill()
+		}
+		c.mu.Unlock()
+	}
+
+	return len(p), nil
+}
+
+func (c *pcmCommand) start() error {
+	cmd := exec.Command("sh", "-c", c.command)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	// The command gets a process group of its own, so the processes started
+	// by the shell are killed with it.
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+
+	err = cmd.Start()
+	if err != nil {
+		return err
+	}
+
+	c.cmd, c.stdin = cmd, stdin
+
+	go func() {
+		err := cmd.Wait()
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		if c.cmd != cmd {
+			return
+		}
+
+		if err == nil {
+			err = errors.New("exited")
+		}
+
+		c.fail(err)
+		c.cmd, c.stdin = nil, nil
+	}()
+
+	return nil
+}
+
+func (c *pcmCommand) stop() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.kill()
+	c.restartAt = time.Time{}
+
+	return nil
+}
+
+func (c *pcmCommand) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.closed = true
+	c.kill()
+}
+
+// kill kills the process group of the command. It must be called with the
+// mutex held.
+func (c *pcmCommand) kill() {
+	if c.cmd == nil {
+		return
+	}
+
+	_ = c.stdin.Close()
+	_ = syscall.Kill(-c.cmd.Process.Pid, syscall.SIGKILL)
+
+	c.cmd, c.stdin = nil, nil
+}
+
+// fail must be called with the mutex held.
+func (c *pcmCommand) fail(err error) {
+	c.err = fmt.Errorf("%w (command: %s): %s", ErrSinkCommand, c.command, err)
+	c.restartAt = time.Now().Add(commandRestartDelay)
+}
+
+func (c *pcmCommand) takeErr() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	err := c.err
+	c.err = nil
+
+	return err
+}
+
+// pcmDuration is the play time of n bytes of PCM.
+func pcmDuration(n int) time.Duration {
+	return time.Duration(n) * time.Second / time.Duration(contextSampleRate*contextNumChannels*2)
+}
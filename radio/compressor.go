@@ -0,0 +1,106 @@
+package radio
+
+import (
+	"math"
+	"time"
+)
+
+// CompressorPolicy controls the night mode compressor. The level above
+// Threshold dBFS is reduced by Ratio, reacting over Attack and recovering
+// over Release. MakeupGain (dB) brings the quiet parts up afterwards and a
+// limiter keeps the peaks below Ceiling dBFS.
+type CompressorPolicy struct {
+	Threshold  float64
+	Ratio      float64
+	Attack     time.Duration
+	Release    time.Duration
+	MakeupGain float64
+	Ceiling    float64
+}
+
+func DefaultCompressorPolicy() CompressorPolicy {
+	return CompressorPolicy{
+		Threshold:  -30,
+		Ratio:      4,
+		Attack:     10 * time.Millisecond,
+		Release:    200 * time.Millisecond,
+		MakeupGain: 9,
+		Ceiling:    -1,
+	}
+}
+
+// compressor is a feed-forward compressor, linked over the channels so the
+// stereo image does not move, followed by a peak limiter.
+type compressor struct {
+	policy    CompressorPolicy
+	channels  int
+	attack    float64
+	release   float64
+	reduction float64
+	ceiling   float64
+	limit     float64
+}
+
+func newCompressor(policy CompressorPolicy, sampleRate, channels int) *compressor {
+	return &compressor{
+		policy:   policy,
+		channels: channels,
+		attack:   smoothing(policy.Attack, sampleRate),
+		release:  smoothing(policy.Release, sampleRate),
+		ceiling:  math.Min(math.Pow(10, policy.Ceiling/20), 1),
+		limit:    1,
+	}
+}
+
+// smoothing returns the coefficient of a one-pole filter with the time constant.
+func smoothing(duration time.Duration, sampleRate int) float64 {
+	if duration <= 0 {
+		return 1
+	}
+
+	return 1 - math.Exp(-1/(duration.Seconds()*float64(sampleRate)))
+}
+
+func (c *compressor) Process(samples []float64) {
+	ratio := math.Max(c.policy.Ratio, 1)
+
+	for i := 0; i+c.channels <= len(samples); i += c.channels {
+		frame := samples[i : i+c.channels]
+
+		peak := 0.0
+		for _, v := range frame {
+			peak = math.Max(peak, math.Abs(v))
+		}
+
+		target := 0.0
+		if peak > 0 {
+			over := 20*math.Log10(peak) - c.policy.Threshold
+			if over > 0 {
+				target = over * (1 - 1/ratio)
+			}
+		}
+
+		if target > c.reduction {
+			c.reduction += (target - c.reduction) * c.attack
+		} else {
+			c.reduction += (target - c.reduction) * c.release
+		}
+
+		gain := math.Pow(10, (c.policy.MakeupGain-c.reduction)/20)
+
+		limit := 1.0
+		if peak*gain > c.ceiling {
+			limit = c.ceiling / (peak * gain)
+		}
+
+		if limit < c.limit {
+			c.limit = limit
+		} else {
+			c.limit += (limit - c.limit) * c.release
+		}
+
+		for ch := range frame {
+			frame[ch] = math.Max(-c.ceiling, math.Min(frame[ch]*gain*c.limit, c.ceiling))
+		}
+	}
+}
@@ -0,0 +1,288 @@
+package radio
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type connection struct {
+	stream     string
+	url        string
+	body       io.ReadCloser
+	decoder    Decoder
+	format     string
+	metadata   Metadata
+	onMetadata func()
+	ended      chan error
+	watchdog   *watchdog
+	buffer     *jitterBuffer
+	normalizer *normalizer
+	tap        *streamTap
+	dsp        *dspReader
+	meter      *meter
+	mu         sync.RWMutex
+}
+
+// streamReader reports the first error of the playback source, which means
+// the stream has ended or failed.
+type streamReader struct {
+	reader io.Reader
+	ended  chan error
+	once   sync.Once
+}
+
+func (r *streamReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if err != nil {
+		r.once.Do(func() {
+			r.ended <- err
+		})
+	}
+
+	return n, err
+}
+
+// open connects to the stream and starts decoding, unless raw, which only
+// detects the format for reading the body as received. Playlists are resolved
+// by trying their entries in order until one of them plays.
+// A server stalling before the decoder is ready counts as a stall as well.
+func (p *Player) open(stream string, depth int, raw bool) (*connection, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, stream, nil)
+	if err != nil {
+		cancel()
+
+		return nil, err
+	}
+
+	timeout := p.stallTimer(cancel)
+	defer timeout.Stop()
+
+	request.Header.Set("Icy-MetaData", "1")
+
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return nil, stalled(ctx, stream, err)
+	}
+
+	if response.StatusCode != http.StatusOK {
+		_ = response.Body.Close()
+
+		return nil, fmt.Errorf("%s: unexpected status: %s", stream, response.Status)
+	}
+
+	conn := &connection{url: stream, body: response.Body, metadata: icyMetadata(stream, response.Header)}
+	if interval, _ := strconv.Atoi(response.Header.Get("icy-metaint")); interval > 0 {
+		conn.body = newICYReader(response.Body, interval, conn.setTitle)
+	}
+
+	conn.tap = newStreamTap(conn.body)
+	conn.body = conn.tap
+
+	contentType := response.Header.Get("Content-Type")
+	reader := bufio.NewReaderSize(conn.body, decoderSniffSize)
+
+	if playlist, ok := detectPlaylist(response.Request.URL, contentType, reader); ok {
+		body, err := io.ReadAll(io.LimitReader(reader, playlistMaxSize))
+		_ = response.Body.Close()
+
+		if err != nil {
+			return nil, stalled(ctx, stream, err)
+		}
+
+		if isHLSPlaylist(body) {
+			return p.openHLS(stream, response.Request.URL, body, raw)
+		}
+
+		if depth >= playlistMaxDepth {
+			return nil, fmt.Errorf("%s: too many nested playlists", stream)
+		}
+
+		streams, err := parsePlaylist(playlist, response.Request.URL, body)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", stream, err)
+		}
+
+		for _, entry := range streams {
+			conn, e := p.open(entry, depth+1, raw)
+			if e == nil {
+				return conn, nil
+			}
+
+			log.Printf("Playlist entry skipped: %s\n", e)
+
+			err = e
+		}
+
+		return nil, fmt.Errorf("%s: no playable entries: %w", stream, err)
+	}
+
+	if raw {
+		conn.format, err = p.decoders.Format(contentType, reader)
+	} else {
+		conn.decoder, conn.format, err = p.decoders.NewDecoder(contentType, reader)
+	}
+
+	if err != nil {
+		_ = response.Body.Close()
+
+		return nil, stalled(ctx, stream, err)
+	}
+
+	return conn, nil
+}
+
+func (p *Player) openHLS(stream string, location *url.URL, body []byte, raw bool) (*connection, error) {
+	reader, err := newHLSReader(location, body)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", stream, err)
+	}
+
+	timeout := p.stallTimer(func() { _ = reader.Close() })
+	defer timeout.Stop()
+
+	tap := newStreamTap(reader)
+
+	var (
+		decoder Decoder
+		format  string
+	)
+
+	if raw {
+		format, err = p.decoders.Format("", bufio.NewReaderSize(tap, decoderSniffSize))
+	} else {
+		decoder, format, err = p.decoders.NewDecoder("", tap)
+	}
+
+	if err != nil {
+		_ = reader.Close()
+
+		return nil, stalled(reader.ctx, stream, err)
+	}
+
+	return &connection{
+		url:      stream,
+		body:     tap,
+		tap:      tap,
+		decoder:  decoder,
+		format:   "hls/" + format,
+		metadata: Metadata{URL: stream},
+	}, nil
+}
+
+// stallTimer aborts the connection with cancel if the decoder is not ready
+// within the watchdog stall timeout.
+func (p *Player) stallTimer(cancel func()) *time.Timer {
+	timeout := p.watchdogPolicy.StallTimeout
+	if timeout <= 0 {
+		timeout = math.MaxInt64
+	}
+
+	return time.AfterFunc(timeout, cancel)
+}
+
+func stalled(ctx context.Context, stream string, err error) error {
+	if ctx.Err() != nil {
+		err = ErrStreamStalled
+	}
+
+	return fmt.Errorf("%s: %w", stream, err)
+}
+
+// reader wraps the playback source, so the end of the stream is reported to
+// the ended channel and stalls or silence to the watchdog alarms. The audio
+// goes through the jitter buffer unless the buffering is disabled.
+func (c *connection) reader(r io.Reader, watchdogPolicy WatchdogPolicy, bufferPolicy BufferPolicy) io.Reader {
+	c.ended = make(chan error, 1)
+	c.watchdog = newWatchdog(r, watchdogPolicy)
+
+	r = c.watchdog
+	if bufferPolicy.Size > 0 {
+		c.buffer = newJitterBuffer(r, bufferPolicy, contextSampleRate, contextNumChannels)
+		r = c.buffer
+	}
+
+	return &streamReader{reader: r, ended: c.ended}
+}
+
+// ready is closed once the stream is buffered enough to start playback.
+func (c *connection) ready() <-chan struct{} {
+	if c.buffer == nil {
+		ready := make(chan struct{})
+		close(ready)
+
+		return ready
+	}
+
+	return c.buffer.Ready()
+}
+
+func (c *connection) BufferStats() BufferStats {
+	if c.buffer == nil {
+		return BufferStats{}
+	}
+
+	return c.buffer.Stats()
+}
+
+func (c *connection) Metadata() Metadata {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	metadata := c.metadata
+	if metadata.Bitrate == 0 && c.decoder != nil {
+		metadata.Bitrate = c.decoder.Bitrate()
+	}
+
+	return metadata
+}
+
+func (c *connection) OnMetadata(handler func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.onMetadata = handler
+}
+
+func (c *connection) setTitle(title string) {
+	c.mu.Lock()
+
+	if c.metadata.Title == title {
+		c.mu.Unlock()
+
+		return
+	}
+
+	c.metadata.Title = title
+	handler := c.onMetadata
+
+	c.mu.Unlock()
+
+	if handler != nil {
+		handler()
+	}
+}
+
+func (c *connection) close() error {
+	err := c.body.Close()
+
+	if c.decoder == nil {
+		return err
+	}
+
+	if e := c.decoder.Close(); e != nil && err == nil {
+		err = e
+	}
+
+	return err
+}
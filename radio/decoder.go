@@ -0,0 +1,141 @@
+package radio
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"strings"
+)
+
+const (
+	decoderSniffSize = 8192
+)
+
+var ErrUnsupportedFormat = errors.New("unsupported stream format")
+
+// Decoder produces interleaved signed 16-bit little-endian PCM from an encoded stream.
+// SampleRate and Channels describe the data returned by the last Read.
+type Decoder interface {
+	io.Reader
+	SampleRate() int
+	Channels() int
+	Bitrate() int
+	Close() error
+}
+
+type DecoderFactory func(r io.Reader) (Decoder, error)
+
+type DecoderFormat struct {
+	Name         string
+	ContentTypes []string
+	Sniff        func(header []byte) bool
+	New          DecoderFactory
+}
+
+type DecoderRegistry struct {
+	formats []DecoderFormat
+}
+
+func NewDecoderRegistry(formats ...DecoderFormat) *DecoderRegistry {
+	return &DecoderRegistry{formats: formats}
+}
+
+func DefaultDecoderRegistry() *DecoderRegistry {
+	return NewDecoderRegistry(
+		AACFormat(),
+		MP3Format(),
+		OggFormat(),
+	)
+}
+
+func (r *DecoderRegistry) Register(format DecoderFormat) *DecoderRegistry {
+	r.formats = append(r.formats, format)
+
+	return r
+}
+
+// NewDecoder picks a format by sniffing the first bytes of the stream and falls back
+// to the content type when none of the formats recognizes the data.
+func (r *DecoderRegistry) NewDecoder(contentType string, stream io.Reader) (Decoder, string, error) {
+	reader := bufio.NewReaderSize(stream, decoderSniffSize)
+
+	header, err := reader.Peek(decoderSniffSize)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, "", err
+	}
+
+	format, ok := r.detect(mediaType(contentType), header)
+	if !ok {
+		return nil, "", fmt.Errorf("%w (content type: %q)", ErrUnsupportedFormat, contentType)
+	}
+
+	decoder, err := format.New(reader)
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: %w", format.Name, err)
+	}
+
+	return decoder, format.Name, nil
+}
+
+// Format names the format of the stream like NewDecoder, without decoding it.
+func (r *DecoderRegistry) Format(contentType string, stream *bufio.Reader) (string, error) {
+	header, err := stream.Peek(decoderSniffSize)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return "", err
+	}
+
+	format, ok := r.detect(mediaType(contentType), header)
+	if !ok {
+		return "", fmt.Errorf("%w (content type: %q)", ErrUnsupportedFormat, contentType)
+	}
+
+	return format.Name, nil
+}
+
+func (r *DecoderRegistry) detect(contentType string, header []byte) (DecoderFormat, bool) {
+	for _, format := range r.formats {
+		if format.Sniff != nil && format.Sniff(header) {
+			return format, true
+		}
+	}
+
+	for _, format := range r.formats {
+		for _, t := range format.ContentTypes {
+			if t == contentType {
+				return format, true
+			}
+		}
+	}
+
+	return DecoderFormat{}, false
+}
+
+func mediaType(contentType string) string {
+	t, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(contentType))
+	}
+
+	return t
+}
+
+func encodePCM16(samples []float32) []byte {
+	pcm := make([]byte, len(samples)*2)
+
+	for i, sample := range samples {
+		if sample > 1 {
+			sample = 1
+		} else if sample < -1 {
+			sample = -1
+		}
+
+		v := int16(sample * math.MaxInt16)
+		pcm[2*i] = byte(v)
+		pcm[2*i+1] = byte(v >> 8)
+	}
+
+	return pcm
+}
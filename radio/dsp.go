@@ -0,0 +1,249 @@
+package radio
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+)
+
+// Filter processes interleaved float samples of the context format in place.
+type Filter interface {
+	Process(samples []float64)
+}
+
+type FilterFactory func(spec FilterSpec, sampleRate, channels int) (Filter, error)
+
+// FilterSpec describes a filter of the DSP chain. Frequency (Hz), Gain (dB)
+// and Q apply to the equalizer types, Value to balance (-1 left to 1 right)
+// and width (0 mono, 1 unchanged, 2 extra wide). Name lets the chain be
+// adjusted by the APIs, like "bass".
+type FilterSpec struct {
+	Name      string
+	Type      string
+	Frequency float64
+	Gain      float64
+	Q         float64
+	Value     float64
+}
+
+var (
+	filterFactories = map[string]FilterFactory{
+		"peaking":   newEQFilter,
+		"lowshelf":  newEQFilter,
+		"highshelf": newEQFilter,
+		"lowpass":   newEQFilter,
+		"highpass":  newEQFilter,
+		"balance":   newBalanceFilter,
+		"mono":      newMonoFilter,
+		"width":     newWidthFilter,
+	}
+	filterFactoriesMu sync.RWMutex
+)
+
+// RegisterFilter adds a filter type to the ones the DSP chains can use.
+func RegisterFilter(filterType string, factory FilterFactory) {
+	filterFactoriesMu.Lock()
+	defer filterFactoriesMu.Unlock()
+
+	filterFactories[filterType] = factory
+}
+
+// ValidateFilters builds the DSP chain without applying it, so several chains
+// can be checked before any of them is set.
+func ValidateFilters(specs ...FilterSpec) error {
+	_, err := newFilters(specs, contextSampleRate, contextNumChannels)
+
+	return err
+}
+
+func newFilters(specs []FilterSpec, sampleRate, channels int) ([]Filter, error) {
+	filterFactoriesMu.RLock()
+	defer filterFactoriesMu.RUnlock()
+
+	filters := make([]Filter, 0, len(specs))
+
+	for _, spec := range specs {
+		factory, ok := filterFactories[spec.Type]
+		if !ok {
+			return nil, fmt.Errorf("unknown filter type: %s", spec.Type)
+		}
+
+		filter, err := factory(spec, sampleRate, channels)
+		if err != nil {
+			return nil, fmt.Errorf("%s filter: %w", spec.Type, err)
+		}
+
+		filters = append(filters, filter)
+	}
+
+	return filters, nil
+}
+
+// dspReader runs the S16LE PCM through the filters. The filters can be
+// replaced while playing, the samples are clipped after the chain.
+type dspReader struct {
+	reader   io.Reader
+	filters  []Filter
+	channels int
+	samples  []float64
+	mu       sync.Mutex
+}
+
+func newDSPReader(reader io.Reader, filters []Filter, channels int) *dspReader {
+	return &dspReader{reader: reader, filters: filters, channels: channels}
+}
+
+func (r *dspReader) SetFilters(filters []Filter) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.filters = filters
+}
+
+func (r *dspReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.filters) == 0 {
+		return n, err
+	}
+
+	count := n / 2
+	count -= count % r.channels
+
+	if cap(r.samples) < count {
+		r.samples = make([]float64, count)
+	}
+
+	samples := r.samples[:count]
+	for i := range samples {
+		samples[i] = float64(int16(binary.LittleEndian.Uint16(p[2*i:]))) / 32768
+	}
+
+	for _, filter := range r.filters {
+		filter.Process(samples)
+	}
+
+	for i, v := range samples {
+		binary.LittleEndian.PutUint16(p[2*i:], uint16(int16(math.Max(-32768, math.Min(math.Round(v*32768), 32767)))))
+	}
+
+	return n, err
+}
+
+type eqFilter struct {
+	biquad   *biquad
+	channels int
+}
+
+// newEQFilter returns the biquad of the RBJ audio EQ cookbook for the type.
+func newEQFilter(spec FilterSpec, sampleRate, channels int) (Filter, error) {
+	if spec.Frequency <= 0 || spec.Frequency >= float64(sampleRate)/2 {
+		return nil, fmt.Errorf("frequency out of range: %g", spec.Frequency)
+	}
+
+	q := spec.Q
+	if q <= 0 {
+		q = math.Sqrt2 / 2
+	}
+
+	a := math.Pow(10, spec.Gain/40)
+	w := 2 * math.Pi * spec.Frequency / float64(sampleRate)
+	cos, alpha := math.Cos(w), math.Sin(w)/(2*q)
+
+	var b0, b1, b2, a0, a1, a2 float64
+
+	switch spec.Type {
+	case "peaking":
+		b0, b1, b2 = 1+alpha*a, -2*cos, 1-alpha*a
+		a0, a1, a2 = 1+alpha/a, -2*cos, 1-alpha/a
+	case "lowshelf":
+		s := 2 * math.Sqrt(a) * alpha
+		b0, b1, b2 = a*((a+1)-(a-1)*cos+s), 2*a*((a-1)-(a+1)*cos), a*((a+1)-(a-1)*cos-s)
+		a0, a1, a2 = (a+1)+(a-1)*cos+s, -2*((a-1)+(a+1)*cos), (a+1)+(a-1)*cos-s
+	case "highshelf":
+		s := 2 * math.Sqrt(a) * alpha
+		b0, b1, b2 = a*((a+1)+(a-1)*cos+s), -2*a*((a-1)+(a+1)*cos), a*((a+1)+(a-1)*cos-s)
+		a0, a1, a2 = (a+1)-(a-1)*cos+s, 2*((a-1)-(a+1)*cos), (a+1)-(a-1)*cos-s
+	case "lowpass":
+		b0, b1, b2 = (1-cos)/2, 1-cos, (1-cos)/2
+		a0, a1, a2 = 1+alpha, -2*cos, 1-alpha
+	case "highpass":
+		b0, b1, b2 = (1+cos)/2, -(1 + cos), (1+cos)/2
+		a0, a1, a2 = 1+alpha, -2*cos, 1-alpha
+	}
+
+	return &eqFilter{
+		biquad: &biquad{
+			b0: b0 / a0,
+			b1: b1 / a0,
+			b2: b2 / a0,
+			a1: a1 / a0,
+			a2: a2 / a0,
+			z1: make([]float64, channels),
+			z2: make([]float64, channels),
+		},
+		channels: channels,
+	}, nil
+}
+
+func (f *eqFilter) Process(samples []float64) {
+	for i := range samples {
+		samples[i] = f.biquad.process(i%f.channels, samples[i])
+	}
+}
+
+type balanceFilter struct {
+	left, right float64
+}
+
+func newBalanceFilter(spec FilterSpec, _, channels int) (Filter, error) {
+	if channels != 2 {
+		return nil, fmt.Errorf("stereo required")
+	}
+
+	value := math.Max(-1, math.Min(spec.Value, 1))
+
+	return &balanceFilter{left: math.Min(1, 1-value), right: math.Min(1, 1+value)}, nil
+}
+
+func (f *balanceFilter) Process(samples []float64) {
+	for i := 0; i+1 < len(samples); i += 2 {
+		samples[i] *= f.left
+		samples[i+1] *= f.right
+	}
+}
+
+type widthFilter struct {
+	width float64
+}
+
+func newMonoFilter(_ FilterSpec, _, channels int) (Filter, error) {
+	if channels != 2 {
+		return nil, fmt.Errorf("stereo required")
+	}
+
+	return &widthFilter{width: 0}, nil
+}
+
+func newWidthFilter(spec FilterSpec, _, channels int) (Filter, error) {
+	if channels != 2 {
+		return nil, fmt.Errorf("stereo required")
+	}
+
+	return &widthFilter{width: math.Max(0, spec.Value)}, nil
+}
+
+// Process scales the side signal (the difference of the channels) by the width.
+func (f *widthFilter) Process(samples []float64) {
+	for i := 0; i+1 < len(samples); i += 2 {
+		mid := (samples[i] + samples[i+1]) / 2
+		side := (samples[i] - samples[i+1]) / 2 * f.width
+
+		samples[i], samples[i+1] = mid+side, mid-side
+	}
+}
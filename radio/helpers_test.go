@@ -0,0 +1,108 @@
+package radio
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const pcmTestMagic = "PCMTEST"
+
+// pcmTestFormat plays the streams starting with its magic as raw PCM at the
+// context sample rate and channels.
+func pcmTestFormat() DecoderFormat {
+	return DecoderFormat{
+		Name: "pcmtest",
+		Sniff: func(header []byte) bool {
+			return bytes.HasPrefix(header, []byte(pcmTestMagic))
+		},
+		New: func(r io.Reader) (Decoder, error) {
+			return &pcmTestDecoder{reader: r}, nil
+		},
+	}
+}
+
+type pcmTestDecoder struct {
+	reader io.Reader
+}
+
+func (d *pcmTestDecoder) Read(p []byte) (int, error) {
+	frameSize := 2 * contextNumChannels
+
+	return io.ReadFull(d.reader, p[:len(p)-len(p)%frameSize])
+}
+
+func (d *pcmTestDecoder) SampleRate() int { return contextSampleRate }
+func (d *pcmTestDecoder) Channels() int   { return contextNumChannels }
+func (d *pcmTestDecoder) Bitrate() int    { return 0 }
+func (d *pcmTestDecoder) Close() error    { return nil }
+
+// newPCMTestServer streams PCM in real time and counts its connections.
+func newPCMTestServer(t *testing.T) (*httptest.Server, *int32) {
+	t.Helper()
+
+	var connections int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&connections, 1)
+
+		w.Header().Set("Content-Type", "application/octet-stream")
+
+		_, err := io.WriteString(w, pcmTestMagic)
+		if err != nil {
+			return
+		}
+
+		block := bytes.Repeat([]byte{1}, contextSampleRate/10*2*contextNumChannels)
+
+		for {
+			_, err = w.Write(block)
+			if err != nil {
+				return
+			}
+
+			w.(http.Flusher).Flush()
+
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(100 * time.Millisecond):
+			}
+		}
+	}))
+
+	t.Cleanup(server.Close)
+
+	return server, &connections
+}
+
+func newTestPlayer(t *testing.T, streams ...string) *Player {
+	t.Helper()
+
+	sink := NewMemorySink()
+	t.Cleanup(func() {
+		_ = sink.Close()
+	})
+
+	p := NewPlayer(streams...)
+	p.RegisterDecoder(pcmTestFormat())
+	p.SetSink(sink)
+	p.SetPowerFade(0)
+	p.SetBufferPolicy(BufferPolicy{Size: time.Second, Prefill: 200 * time.Millisecond})
+
+	p.Play(1)
+
+	for deadline := time.Now().Add(5 * time.Second); p.State() != StatePlaying; {
+		if time.Now().After(deadline) {
+			t.Fatalf("not playing: %s", p.State())
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	return p
+}
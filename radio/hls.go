@@ -0,0 +1,465 @@
+package radio
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	hlsLiveEdgeSegments  = 3
+	hlsBufferedSegments  = 3
+	hlsMaxRetries        = 3
+	hlsRetryDelay        = time.Second
+	hlsMaxPlaylistSize   = 1 << 20
+	hlsMaxSegmentSize    = 16 << 20
+	hlsMinReloadInterval = time.Second
+)
+
+var (
+	ErrHLSEncrypted   = errors.New("encrypted HLS streams are not supported")
+	ErrHLSUnsupported = errors.New("fragmented MP4 HLS streams are not supported")
+)
+
+type hlsVariant struct {
+	url       string
+	bandwidth int
+	codecs    string
+	audio     string
+}
+
+type hlsRendition struct {
+	url       string
+	group     string
+	isDefault bool
+}
+
+type hlsSegment struct {
+	url      string
+	sequence int
+}
+
+type hlsPlaylist struct {
+	variants       []hlsVariant
+	renditions     []hlsRendition
+	segments       []hlsSegment
+	targetDuration time.Duration
+	ended          bool
+}
+
+func (p *hlsPlaylist) isMaster() bool {
+	return len(p.variants) > 0
+}
+
+func isHLSPlaylist(body []byte) bool {
+	return bytes.Contains(body, []byte("#EXT-X-"))
+}
+
+func parseHLSPlaylist(location *url.URL, body []byte) (*hlsPlaylist, error) {
+	playlist := &hlsPlaylist{targetDuration: 10 * time.Second}
+	sequence := 0
+
+	var variant *hlsVariant
+
+	for _, line := range strings.Split(string(body), "\n") {
+		line = strings.TrimSpace(line)
+
+		switch {
+		case line == "":
+			continue
+		case strings.HasPrefix(line, "#EXT-X-STREAM-INF:"):
+			attrs := parseHLSAttributes(strings.TrimPrefix(line, "#EXT-X-STREAM-INF:"))
+			bandwidth, _ := strconv.Atoi(attrs["BANDWIDTH"])
+			variant = &hlsVariant{bandwidth: bandwidth, codecs: attrs["CODECS"], audio: attrs["AUDIO"]}
+		case strings.HasPrefix(line, "#EXT-X-MEDIA:"):
+			attrs := parseHLSAttributes(strings.TrimPrefix(line, "#EXT-X-MEDIA:"))
+			if attrs["TYPE"] != "AUDIO" || attrs["URI"] == "" {
+				continue
+			}
+
+			playlist.renditions = append(playlist.renditions, hlsRendition{
+				url:       resolveURL(location, attrs["URI"]),
+				group:     attrs["GROUP-ID"],
+				isDefault: attrs["DEFAULT"] == "YES",
+			})
+		case strings.HasPrefix(line, "#EXT-X-TARGETDURATION:"):
+			seconds, err := strconv.Atoi(strings.TrimPrefix(line, "#EXT-X-TARGETDURATION:"))
+			if err == nil && seconds > 0 {
+				playlist.targetDuration = time.Duration(seconds) * time.Second
+			}
+		case strings.HasPrefix(line, "#EXT-X-MEDIA-SEQUENCE:"):
+			sequence, _ = strconv.Atoi(strings.TrimPrefix(line, "#EXT-X-MEDIA-SEQUENCE:"))
+		case strings.HasPrefix(line, "#EXT-X-KEY:"):
+			attrs := parseHLSAttributes(strings.TrimPrefix(line, "#EXT-X-KEY:"))
+			if attrs["METHOD"] != "NONE" {
+				return nil, ErrHLSEncrypted
+			}
+		case strings.HasPrefix(line, "#EXT-X-MAP:"):
+			return nil, ErrHLSUnsupported
+		case line == "#EXT-X-ENDLIST":
+			playlist.ended = true
+		case strings.HasPrefix(line, "#"):
+			continue
+		case variant != nil:
+			variant.url = resolveURL(location, line)
+			playlist.variants = append(playlist.variants, *variant)
+			variant = nil
+		default:
+			playlist.segments = append(playlist.segments, hlsSegment{url: resolveURL(location, line), sequence: sequence})
+			sequence++
+		}
+	}
+
+	return playlist, nil
+}
+
+// audioPlaylist picks the media playlist to play from a master playlist:
+// the default audio rendition of a variant if there is one, otherwise the
+// best audio-only variant, otherwise the cheapest variant.
+func (p *hlsPlaylist) audioPlaylist() (string, error) {
+	var audioOnly, cheapest *hlsVariant
+
+	for i := range p.variants {
+		variant := &p.variants[i]
+
+		if variant.audio != "" {
+			var rendition *hlsRendition
+			for j := range p.renditions {
+				if p.renditions[j].group == variant.audio && (rendition == nil || p.renditions[j].isDefault) {
+					rendition = &p.renditions[j]
+				}
+			}
+
+			if rendition != nil {
+				return rendition.url, nil
+			}
+		}
+
+		if isAudioCodecs(variant.codecs) && (audioOnly == nil || variant.bandwidth > audioOnly.bandwidth) {
+			audioOnly = variant
+		}
+
+		if cheapest == nil || variant.bandwidth < cheapest.bandwidth {
+			cheapest = variant
+		}
+	}
+
+	switch {
+	case audioOnly != nil:
+		return audioOnly.url, nil
+	case cheapest != nil:
+		return cheapest.url, nil
+	default:
+		return "", ErrEmptyPlaylist
+	}
+}
+
+func isAudioCodecs(codecs string) bool {
+	if codecs == "" {
+		return false
+	}
+
+	for _, codec := range strings.Split(codecs, ",") {
+		codec = strings.TrimSpace(codec)
+		if !strings.HasPrefix(codec, "mp4a") && codec != "mp3" && codec != "ac-3" && codec != "ec-3" {
+			return false
+		}
+	}
+
+	return true
+}
+
+func parseHLSAttributes(list string) map[string]string {
+	attrs := make(map[string]string)
+
+	for list != "" {
+		eq := strings.IndexByte(list, '=')
+		if eq < 0 {
+			break
+		}
+
+		key := strings.TrimSpace(list[:eq])
+		list = list[eq+1:]
+
+		var value string
+		if strings.HasPrefix(list, `"`) {
+			end := strings.IndexByte(list[1:], '"')
+			if end < 0 {
+				end = len(list) - 1
+			}
+
+			value = list[1 : end+1]
+			list = list[end+1:]
+			if len(list) > 0 {
+				list = list[1:]
+			}
+		} else {
+			end := strings.IndexByte(list, ',')
+			if end < 0 {
+				end = len(list)
+			}
+
+			value = list[:end]
+			list = list[end:]
+		}
+
+		attrs[key] = value
+		list = strings.TrimPrefix(list, ",")
+	}
+
+	return attrs
+}
+
+func resolveURL(location *url.URL, ref string) string {
+	u, err := url.Parse(ref)
+	if err != nil {
+		return ref
+	}
+
+	return location.ResolveReference(u).String()
+}
+
+type hlsChunk struct {
+	data []byte
+	err  error
+}
+
+// hlsReader follows a live media playlist and turns its segments into a
+// continuous elementary audio stream (ADTS or MPEG audio), so the regular
+// decoders play it without gaps between segments.
+type hlsReader struct {
+	playlist string
+	chunks   chan hlsChunk
+	pending  []byte
+	err      error
+	ctx      context.Context
+	cancel   context.CancelFunc
+	once     sync.Once
+}
+
+func newHLSReader(location *url.URL, body []byte) (*hlsReader, error) {
+	playlist, err := parseHLSPlaylist(location, body)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	r := &hlsReader{
+		playlist: location.String(),
+		chunks:   make(chan hlsChunk, hlsBufferedSegments),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+
+	if playlist.isMaster() {
+		r.playlist, err = playlist.audioPlaylist()
+		if err != nil {
+			cancel()
+
+			return nil, err
+		}
+
+		playlist = nil
+	}
+
+	go r.run(playlist)
+
+	return r, nil
+}
+
+func (r *hlsReader) Read(p []byte) (int, error) {
+	for len(r.pending) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+
+		chunk, ok := <-r.chunks
+		if !ok {
+			r.err = io.EOF
+
+			continue
+		}
+
+		r.pending, r.err = chunk.data, chunk.err
+	}
+
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+
+	return n, nil
+}
+
+func (r *hlsReader) Close() error {
+	r.once.Do(r.cancel)
+
+	return nil
+}
+
+func (r *hlsReader) run(playlist *hlsPlaylist) {
+	defer close(r.chunks)
+
+	demuxer := newTSDemuxer()
+	next := -1
+
+	for {
+		loadedAt := time.Now()
+
+		if playlist == nil {
+			body, err := r.fetch(r.playlist, hlsMaxPlaylistSize)
+			if err != nil {
+				r.send(hlsChunk{err: err})
+
+				return
+			}
+
+			location, _ := url.Parse(r.playlist)
+
+			playlist, err = parseHLSPlaylist(location, body)
+			if err != nil {
+				r.send(hlsChunk{err: err})
+
+				return
+			}
+		}
+
+		segments := playlist.segments
+		switch {
+		case next < 0 && !playlist.ended && len(segments) > hlsLiveEdgeSegments:
+			segments = segments[len(segments)-hlsLiveEdgeSegments:]
+		case next >= 0:
+			for len(segments) > 0 && segments[0].sequence < next {
+				segments = segments[1:]
+			}
+		}
+
+		for _, segment := range segments {
+			if next >= 0 && segment.sequence > next {
+				log.Printf("HLS segments skipped (playlist: %s, sequence: %d-%d)\n", r.playlist, next, segment.sequence-1)
+			}
+
+			data, err := r.fetch(segment.url, hlsMaxSegmentSize)
+			if err == nil {
+				data, err = demuxSegment(demuxer, data)
+			}
+
+			if err != nil {
+				if r.ctx.Err() != nil {
+					return
+				}
+
+				log.Printf("HLS segment skipped (url: %s): %s\n", segment.url, err)
+			} else if !r.send(hlsChunk{data: data}) {
+				return
+			}
+
+			next = segment.sequence + 1
+		}
+
+		if playlist.ended {
+			return
+		}
+
+		// Reload after the target duration, or half of it if nothing changed.
+		interval := playlist.targetDuration
+		if len(segments) == 0 {
+			interval /= 2
+		}
+
+		if interval < hlsMinReloadInterval {
+			interval = hlsMinReloadInterval
+		}
+
+		playlist = nil
+
+		select {
+		case <-time.After(time.Until(loadedAt.Add(interval))):
+		case <-r.ctx.Done():
+			return
+		}
+	}
+}
+
+func (r *hlsReader) send(chunk hlsChunk) bool {
+	select {
+	case r.chunks <- chunk:
+		return true
+	case <-r.ctx.Done():
+		return false
+	}
+}
+
+func (r *hlsReader) fetch(location string, limit int64) ([]byte, error) {
+	var err error
+
+	for i := 0; i < hlsMaxRetries; i++ {
+		if i > 0 {
+			select {
+			case <-time.After(hlsRetryDelay):
+			case <-r.ctx.Done():
+				return nil, r.ctx.Err()
+			}
+		}
+
+		var body []byte
+
+		body, err = r.fetchOnce(location, limit)
+		if err == nil || r.ctx.Err() != nil {
+			return body, err
+		}
+	}
+
+	return nil, err
+}
+
+func (r *hlsReader) fetchOnce(location string, limit int64) ([]byte, error) {
+	request, err := http.NewRequestWithContext(r.ctx, http.MethodGet, location, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		_ = response.Body.Close()
+	}()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: unexpected status: %s", location, response.Status)
+	}
+
+	return io.ReadAll(io.LimitReader(response.Body, limit))
+}
+
+// demuxSegment extracts the audio of an MPEG-TS segment, or strips the ID3
+// timestamp tag of a packed audio segment.
+func demuxSegment(demuxer *tsDemuxer, data []byte) ([]byte, error) {
+	if isTransportStream(data) {
+		return demuxer.Demux(data)
+	}
+
+	if len(data) >= 10 && string(data[:3]) == "ID3" {
+		size := int(data[6]&0x7F)<<21 | int(data[7]&0x7F)<<14 | int(data[8]&0x7F)<<7 | int(data[9]&0x7F)
+		if data[5]&0x10 != 0 {
+			size += 10
+		}
+
+		if 10+size <= len(data) {
+			data = data[10+size:]
+		}
+	}
+
+	return data, nil
+}
@@ -0,0 +1,189 @@
+package radio
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	icyMetadataBlockSize = 16
+)
+
+// httpClient understands the "ICY 200 OK" status line of Shoutcast v1 servers,
+// which the standard client rejects as a malformed HTTP version.
+var httpClient = func() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	dialer := &net.Dialer{}
+
+	transport.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+		conn, err := dialer.DialContext(ctx, network, address)
+		if err != nil {
+			return nil, err
+		}
+
+		return &icyConn{Conn: conn}, nil
+	}
+
+	return &http.Client{Transport: transport}
+}()
+
+type icyConn struct {
+	net.Conn
+	checked bool
+	prefix  []byte
+}
+
+func (c *icyConn) Read(p []byte) (int, error) {
+	if !c.checked {
+		c.checked = true
+
+		status := make([]byte, 4)
+
+		n, err := io.ReadFull(c.Conn, status)
+		if err != nil && n == 0 {
+			return 0, err
+		}
+
+		c.prefix = status[:n]
+		if string(c.prefix) == "ICY " {
+			c.prefix = []byte("HTTP/1.0 ")
+		}
+	}
+
+	if len(c.prefix) > 0 {
+		n := copy(p, c.prefix)
+		c.prefix = c.prefix[n:]
+
+		return n, nil
+	}
+
+	return c.Conn.Read(p)
+}
+
+type Metadata struct {
+	Stream  int
+	URL     string
+	Title   string
+	Name    string
+	Genre   string
+	Bitrate int
+}
+
+func icyMetadata(stream string, header http.Header) Metadata {
+	bitrate, _ := strconv.Atoi(strings.SplitN(header.Get("icy-br"), ",", 2)[0])
+
+	return Metadata{
+		URL:     stream,
+		Name:    icyString(header.Get("icy-name")),
+		Genre:   icyString(header.Get("icy-genre")),
+		Bitrate: bitrate,
+	}
+}
+
+// icyReader strips the metadata blocks interleaved into the audio data
+// every interval bytes and reports the stream titles found in them.
+type icyReader struct {
+	reader    io.ReadCloser
+	interval  int
+	remaining int
+	onTitle   func(title string)
+}
+
+func newICYReader(reader io.ReadCloser, interval int, onTitle func(title string)) *icyReader {
+	return &icyReader{reader: reader, interval: interval, remaining: interval, onTitle: onTitle}
+}
+
+func (r *icyReader) Read(p []byte) (int, error) {
+	if r.remaining == 0 {
+		err := r.readMetadata()
+		if err != nil {
+			return 0, err
+		}
+
+		r.remaining = r.interval
+	}
+
+	if len(p) > r.remaining {
+		p = p[:r.remaining]
+	}
+
+	n, err := r.reader.Read(p)
+	r.remaining -= n
+
+	return n, err
+}
+
+func (r *icyReader) Close() error {
+	return r.reader.Close()
+}
+
+func (r *icyReader) readMetadata() error {
+	var length [1]byte
+
+	_, err := io.ReadFull(r.reader, length[:])
+	if err != nil {
+		return err
+	}
+
+	if length[0] == 0 {
+		return nil
+	}
+
+	block := make([]byte, int(length[0])*icyMetadataBlockSize)
+
+	_, err = io.ReadFull(r.reader, block)
+	if err != nil {
+		return err
+	}
+
+	if title, ok := parseStreamTitle(string(block)); ok {
+		r.onTitle(title)
+	}
+
+	return nil
+}
+
+func parseStreamTitle(block string) (string, bool) {
+	const prefix = "StreamTitle='"
+
+	start := strings.Index(block, prefix)
+	if start < 0 {
+		return "", false
+	}
+
+	block = block[start+len(prefix):]
+
+	// Titles may contain quotes, so the value ends with the "';" terminator.
+	end := strings.Index(block, "';")
+	if end < 0 {
+		end = strings.IndexByte(block, 0)
+		if end < 0 {
+			end = len(block)
+		}
+
+		block = strings.TrimSuffix(block[:end], "'")
+		end = len(block)
+	}
+
+	return icyString(block[:end]), true
+}
+
+// icyString decodes the values of servers still sending Latin-1 instead of UTF-8.
+func icyString(s string) string {
+	s = strings.TrimSpace(s)
+	if utf8.ValidString(s) {
+		return s
+	}
+
+	runes := make([]rune, len(s))
+	for i := 0; i < len(s); i++ {
+		runes[i] = rune(s[i])
+	}
+
+	return string(runes)
+}
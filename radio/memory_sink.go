@@ -0,0 +1,102 @@
+package radio
+
+import (
+	"io"
+	"time"
+)
+
+const (
+	memorySinkDuration = time.Minute
+)
+
+// NewNullSink returns a sink discarding the audio in real time, for running
+// without a sound card.
+func NewNullSink() Sink {
+	return newMixerSink(io.Discard)
+}
+
+// MemorySink keeps the last minute of the mixed PCM in memory, so the
+// output can be checked.
+type MemorySink struct {
+	*mixerSink
+	ring *pcmRing
+}
+
+func NewMemorySink() *MemorySink {
+	frameSize := 2 * contextNumChannels
+
+	return newMemorySink(frames(memorySinkDuration, contextSampleRate*frameSize, frameSize))
+}
+
+func newMemorySink(size int) *MemorySink {
+	s := &MemorySink{ring: &pcmRing{data: make([]byte, size)}}
+	s.mixerSink = newMixerSink(s.ring)
+
+	return s
+}
+
+// Bytes returns a copy of the S16LE PCM played last.
+func (s *MemorySink) Bytes() []byte {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	return s.ring.bytes()
+}
+
+// Reset discards the PCM played so far.
+func (s *MemorySink) Reset() {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	s.ring.reset()
+}
+
+// pcmRing keeps the last bytes written to it, the older ones are overwritten.
+// It is guarded by the write mutex of the mixer.
+type pcmRing struct {
+	data []byte
+	next int
+	size int
+}
+
+func (r *pcmRing) Write(p []byte) (int, error) {
+	n := len(p)
+
+	if len(p) > len(r.data) {
+		p = p[len(p)-len(r.data):]
+	}
+
+	for len(p) > 0 {
+		copied := copy(r.data[r.next:], p)
+		p = p[copied:]
+		r.next = (r.next + copied) % len(r.data)
+		r.size += copied
+	}
+
+	if r.size > len(r.data) {
+		r.size = len(r.data)
+	}
+
+	return n, nil
+}
+
+func (r *pcmRing) bytes() []byte {
+	start := r.next - r.size
+	if start < 0 {
+		start += len(r.data)
+	}
+
+	b := make([]byte, 0, r.size)
+
+	if start+r.size <= len(r.data) {
+		return append(b, r.data[start:start+r.size]...)
+	}
+
+	b = append(b, r.data[start:]...)
+
+	return append(b, r.data[:r.next]...)
+}
+
+func (r *pcmRing) reset() {
+	r.next, r.size = 0, 0
+}
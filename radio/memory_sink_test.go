@@ -0,0 +1,257 @@
+package radio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+	"time"
+)
+
+// constantSource is an endless S16LE source of a single sample value.
+type constantSource int16
+
+func (s constantSource) Read(p []byte) (int, error) {
+	n := len(p) - len(p)%2
+	for i := 0; i < n; i += 2 {
+		binary.LittleEndian.PutUint16(p[i:], uint16(s))
+	}
+
+	return n, nil
+}
+
+func samples(t *testing.T, pcm []byte) []int16 {
+	t.Helper()
+
+	if len(pcm)%2 != 0 {
+		t.Fatalf("odd PCM size: %d", len(pcm))
+	}
+
+	values := make([]int16, len(pcm)/2)
+	for i := range values {
+		values[i] = int16(binary.LittleEndian.Uint16(pcm[2*i:]))
+	}
+
+	return values
+}
+
+func playMemorySink(t *testing.T, sink *MemorySink, d time.Duration) []int16 {
+	t.Helper()
+
+	err := sink.Resume()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(d)
+
+	err = sink.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return samples(t, sink.Bytes())
+}
+
+func TestMemorySinkMixesStreams(t *testing.T) {
+	sink := NewMemorySink()
+
+	first := sink.NewStream(constantSource(1000))
+	first.Play()
+
+	second := sink.NewStream(constantSource(2000))
+	second.SetVolume(0.5)
+	second.Play()
+
+	// Created but not played, it is not mixed.
+	sink.NewStream(constantSource(5000))
+
+	values := playMemorySink(t, sink, 10*mixerBlockDuration)
+	if len(values) == 0 {
+		t.Fatal("no PCM played")
+	}
+
+	for i, v := range values {
+		if v != 2000 {
+			t.Fatalf("sample %d: got %d, want 2000", i, v)
+		}
+	}
+}
+
+func TestMemorySinkClipsMix(t *testing.T) {
+	tests := []struct {
+		sample int16
+		want   int16
+	}{
+		{sample: 20000, want: 32767},
+		{sample: -20000, want: -32768},
+	}
+
+	for _, test := range tests {
+		sink := NewMemorySink()
+
+		sink.NewStream(constantSource(test.sample)).Play()
+		sink.NewStream(constantSource(test.sample)).Play()
+
+		for i, v := range playMemorySink(t, sink, 5*mixerBlockDuration) {
+			if v != test.want {
+				t.Fatalf("%d twice, sample %d: got %d, want %d", test.sample, i, v, test.want)
+			}
+		}
+	}
+}
+
+func TestMemorySinkEndsStreamOnEOF(t *testing.T) {
+	sink := NewMemorySink()
+
+	// A single frame, the rest of the block is silence.
+	frame := make([]byte, 2*contextNumChannels)
+	for i := 0; i < len(frame); i += 2 {
+		binary.LittleEndian.PutUint16(frame[i:], 1234)
+	}
+
+	sink.NewStream(bytes.NewReader(frame)).Play()
+
+	values := playMemorySink(t, sink, 10*mixerBlockDuration)
+
+	blockSize := int(mixerBlockDuration.Seconds()*contextSampleRate) * contextNumChannels
+	if len(values) != blockSize {
+		t.Fatalf("got %d samples, want a single block of %d", len(values), blockSize)
+	}
+
+	for i, v := range values {
+		want := int16(0)
+		if i < contextNumChannels {
+			want = 1234
+		}
+
+		if v != want {
+			t.Fatalf("sample %d: got %d, want %d", i, v, want)
+		}
+	}
+}
+
+func TestMemorySinkSuspend(t *testing.T) {
+	sink := NewMemorySink()
+	sink.NewStream(constantSource(1)).Play()
+
+	err := sink.Resume()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(5 * mixerBlockDuration)
+
+	err = sink.Suspend()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A block may be mixing while suspending.
+	time.Sleep(2 * mixerBlockDuration)
+	played := len(sink.Bytes())
+
+	time.Sleep(5 * mixerBlockDuration)
+
+	if n := len(sink.Bytes()); n != played {
+		t.Fatalf("played %d bytes while suspended", n-played)
+	}
+
+	_ = sink.Close()
+}
+
+func TestMemorySinkClose(t *testing.T) {
+	sink := NewMemorySink()
+	sink.NewStream(constantSource(1)).Play()
+
+	played := len(playMemorySink(t, sink, 5*mixerBlockDuration))
+
+	time.Sleep(5 * mixerBlockDuration)
+
+	if n := len(sink.Bytes()) / 2; n != played {
+		t.Fatalf("played %d samples after close", n-played)
+	}
+
+	if err := sink.Resume(); err != ErrSinkClosed {
+		t.Fatalf("resume after close: got %v, want %v", err, ErrSinkClosed)
+	}
+
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMemorySinkKeepsLastPCM(t *testing.T) {
+	sink := newMemorySink(8)
+
+	write := func(s string) {
+		_, err := io.WriteString(sink.ring, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		write string
+		want  string
+	}{
+		{write: "", want: ""},
+		{write: "abc", want: "abc"},
+		{write: "defgh", want: "abcdefgh"},
+		{write: "ij", want: "cdefghij"},
+		{write: "klmnopqrstu", want: "nopqrstu"},
+		{write: "v", want: "opqrstuv"},
+	}
+
+	for _, test := range tests {
+		write(test.write)
+
+		if got := string(sink.Bytes()); got != test.want {
+			t.Fatalf("after %q: got %q, want %q", test.write, got, test.want)
+		}
+	}
+
+	sink.Reset()
+	write("wx")
+
+	if got := string(sink.Bytes()); got != "wx" {
+		t.Fatalf("after reset: got %q, want %q", got, "wx")
+	}
+}
+
+func TestPlayerPlaysToMemorySink(t *testing.T) {
+	server, _ := newPCMTestServer(t)
+
+	p := newTestPlayer(t, server.URL)
+	t.Cleanup(p.Stop)
+
+	sink := p.sink.(*MemorySink)
+
+	// The sink plays silence until the stream is buffered and faded in.
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		values := samples(t, sink.Bytes())
+		if len(values) > 0 && values[len(values)-1] != 0 {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("played %d samples of silence", len(values))
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	time.Sleep(300 * time.Millisecond)
+
+	// The stream is PCM of bytes 1, played at full volume.
+	const want = 0x0101
+
+	values := samples(t, sink.Bytes())
+	block := int(0.2*contextSampleRate) * contextNumChannels
+
+	for i, v := range values[len(values)-block:] {
+		if v != want {
+			t.Fatalf("sample %d of the last 200ms: got %d, want %d", i, v, want)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package radio
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	meterIntegration = 300 * time.Millisecond
+	meterPeakDecay   = 1500 * time.Millisecond
+	meterFloor       = -100.0
+)
+
+// Levels are the RMS and peak levels per channel, in dBFS. The RMS is
+// averaged over 300 ms and the peak decays over 1.5 s, like a PPM.
+type Levels struct {
+	RMS  []float64
+	Peak []float64
+}
+
+func silentLevels(channels int) Levels {
+	levels := Levels{RMS: make([]float64, channels), Peak: make([]float64, channels)}
+	for ch := 0; ch < channels; ch++ {
+		levels.RMS[ch], levels.Peak[ch] = meterFloor, meterFloor
+	}
+
+	return levels
+}
+
+// meter measures the S16LE PCM read through it.
+type meter struct {
+	reader   io.Reader
+	channels int
+	square   []float64
+	peak     []float64
+	rms      float64
+	decay    float64
+	mu       sync.Mutex
+}
+
+func newMeter(reader io.Reader, sampleRate, channels int) *meter {
+	return &meter{
+		reader:   reader,
+		channels: channels,
+		square:   make([]float64, channels),
+		peak:     make([]float64, channels),
+		rms:      smoothing(meterIntegration, sampleRate),
+		decay:    math.Exp(-1 / (meterPeakDecay.Seconds() * float64(sampleRate))),
+	}
+}
+
+func (m *meter) Read(p []byte) (int, error) {
+	n, err := m.reader.Read(p)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := 0; i+2 <= n; i += 2 {
+		ch := (i / 2) % m.channels
+		x := float64(int16(binary.LittleEndian.Uint16(p[i:]))) / 32768
+
+		m.square[ch] += (x*x - m.square[ch]) * m.rms
+		m.peak[ch] = math.Max(math.Abs(x), m.peak[ch]*m.decay)
+	}
+
+	return n, err
+}
+
+// Levels returns the levels scaled by the gain the audio is played at.
+func (m *meter) Levels(gain float64) Levels {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	levels := Levels{RMS: make([]float64, m.channels), Peak: make([]float64, m.channels)}
+	for ch := 0; ch < m.channels; ch++ {
+		levels.RMS[ch] = decibels(math.Sqrt(m.square[ch]) * gain)
+		levels.Peak[ch] = decibels(m.peak[ch] * gain)
+	}
+
+	return levels
+}
+
+func decibels(v float64) float64 {
+	if v <= 0 {
+		return meterFloor
+	}
+
+	return math.Max(20*math.Log10(v), meterFloor)
+}
@@ -0,0 +1,179 @@
+package radio
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/tosone/minimp3"
+)
+
+var (
+	mp3BitratesV1 = [3][15]int{
+		{0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448},
+		{0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384},
+		{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320},
+	}
+	mp3BitratesV2 = [3][15]int{
+		{0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256},
+		{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+		{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+	}
+	mp3SampleRates = [4][3]int{
+		{11025, 12000, 8000},
+		{0, 0, 0},
+		{22050, 24000, 16000},
+		{44100, 48000, 32000},
+	}
+)
+
+type mp3Decoder struct {
+	decoder *minimp3.Decoder
+	source  *sourceReader
+}
+
+// sourceReader remembers the error that stopped the minimp3 reading goroutine.
+type sourceReader struct {
+	reader io.Reader
+	err    error
+	mu     sync.Mutex
+}
+
+func (r *sourceReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if err != nil {
+		r.mu.Lock()
+		r.err = err
+		r.mu.Unlock()
+	}
+
+	return n, err
+}
+
+func (r *sourceReader) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.err
+}
+
+func MP3Format() DecoderFormat {
+	return DecoderFormat{
+		Name:         "mp3",
+		ContentTypes: []string{"audio/mpeg", "audio/mp3", "audio/mpeg3", "audio/x-mpeg", "audio/x-mp3"},
+		Sniff:        sniffMP3,
+		New:          newMP3Decoder,
+	}
+}
+
+func newMP3Decoder(r io.Reader) (Decoder, error) {
+	source := &sourceReader{reader: r}
+
+	decoder, err := minimp3.NewDecoder(source)
+	if err != nil {
+		return nil, err
+	}
+
+	if isStarted := <-decoder.Started(); !isStarted {
+		decoder.Close()
+
+		return nil, errors.New("cannot start decoding")
+	}
+
+	return &mp3Decoder{decoder: decoder, source: source}, nil
+}
+
+// Read waits for decoded data instead of returning io.EOF whenever the minimp3
+// buffer runs empty, so only the end or the failure of the stream stops playback.
+func (d *mp3Decoder) Read(p []byte) (int, error) {
+	for {
+		n, err := d.decoder.Read(p)
+		if n > 0 || err != io.EOF {
+			return n, err
+		}
+
+		if err := d.source.Err(); err != nil {
+			return 0, err
+		}
+
+		time.Sleep(minimp3.WaitForDataDuration)
+	}
+}
+
+func (d *mp3Decoder) SampleRate() int {
+	return d.decoder.SampleRate
+}
+
+func (d *mp3Decoder) Channels() int {
+	return d.decoder.Channels
+}
+
+func (d *mp3Decoder) Bitrate() int {
+	return d.decoder.Kbps
+}
+
+func (d *mp3Decoder) Close() error {
+	d.decoder.Close()
+
+	return nil
+}
+
+func sniffMP3(header []byte) bool {
+	if len(header) >= 3 && string(header[:3]) == "ID3" {
+		return true
+	}
+
+	// Two consecutive valid frame headers are required to tell MPEG audio from random bytes.
+	for i := 0; i+4 <= len(header); i++ {
+		length, ok := mp3FrameLength(header[i:])
+		if !ok {
+			continue
+		}
+
+		if i+length+4 > len(header) {
+			continue
+		}
+
+		if _, ok := mp3FrameLength(header[i+length:]); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func mp3FrameLength(header []byte) (int, bool) {
+	if len(header) < 4 || header[0] != 0xFF || header[1]&0xE0 != 0xE0 {
+		return 0, false
+	}
+
+	version := int(header[1]>>3) & 0x03
+	layer := int(header[1]>>1) & 0x03
+	bitrateIndex := int(header[2] >> 4)
+	sampleRateIndex := int(header[2]>>2) & 0x03
+	padding := int(header[2]>>1) & 0x01
+
+	if version == 1 || layer == 0 || bitrateIndex == 0 || bitrateIndex == 0x0F || sampleRateIndex == 3 {
+		return 0, false
+	}
+
+	layerIndex := 3 - layer
+	sampleRate := mp3SampleRates[version][sampleRateIndex]
+
+	var bitrate int
+	if version == 3 {
+		bitrate = mp3BitratesV1[layerIndex][bitrateIndex] * 1000
+	} else {
+		bitrate = mp3BitratesV2[layerIndex][bitrateIndex] * 1000
+	}
+
+	switch {
+	case layerIndex == 0:
+		return (12*bitrate/sampleRate + padding) * 4, true
+	case layerIndex == 2 && version != 3:
+		return 72*bitrate/sampleRate + padding, true
+	default:
+		return 144*bitrate/sampleRate + padding, true
+	}
+}
@@ -0,0 +1,166 @@
+package radio
+
+import (
+	"errors"
+)
+
+const (
+	tsPacketSize = 188
+	tsSyncByte   = 0x47
+	tsPIDPAT     = 0x0000
+
+	tsStreamTypeMPEG1Audio = 0x03
+	tsStreamTypeMPEG2Audio = 0x04
+	tsStreamTypeADTS       = 0x0F
+)
+
+var errNoAudioTrack = errors.New("mpegts: no supported audio track")
+
+// tsDemuxer extracts the elementary stream of the first MPEG or ADTS audio
+// track from MPEG-TS segments. The state is kept between segments, so the
+// PES payloads of consecutive segments join into a continuous stream.
+type tsDemuxer struct {
+	pmtPID   int
+	audioPID int
+}
+
+func newTSDemuxer() *tsDemuxer {
+	return &tsDemuxer{pmtPID: -1, audioPID: -1}
+}
+
+func isTransportStream(data []byte) bool {
+	return len(data) >= 2*tsPacketSize && data[0] == tsSyncByte && data[tsPacketSize] == tsSyncByte
+}
+
+func (d *tsDemuxer) Demux(data []byte) ([]byte, error) {
+	var out []byte
+
+	for len(data) >= tsPacketSize {
+		if data[0] != tsSyncByte {
+			data = data[1:]
+
+			continue
+		}
+
+		packet := data[:tsPacketSize]
+		data = data[tsPacketSize:]
+
+		start := packet[1]&0x40 != 0
+		pid := int(packet[1]&0x1F)<<8 | int(packet[2])
+
+		payload, ok := tsPayload(packet)
+		if !ok {
+			continue
+		}
+
+		switch {
+		case pid == tsPIDPAT && start:
+			d.parsePAT(payload)
+		case pid == d.pmtPID && start:
+			d.parsePMT(payload)
+		case pid == d.audioPID:
+			if start {
+				payload = pesPayload(payload)
+			}
+
+			out = append(out, payload...)
+		}
+	}
+
+	if d.audioPID < 0 {
+		return nil, errNoAudioTrack
+	}
+
+	return out, nil
+}
+
+func (d *tsDemuxer) parsePAT(payload []byte) {
+	section, ok := psiSection(payload)
+	if !ok {
+		return
+	}
+
+	for i := 8; i+4 <= len(section)-4; i += 4 {
+		program := int(section[i])<<8 | int(section[i+1])
+		if program == 0 {
+			continue
+		}
+
+		d.pmtPID = int(section[i+2]&0x1F)<<8 | int(section[i+3])
+
+		return
+	}
+}
+
+func (d *tsDemuxer) parsePMT(payload []byte) {
+	section, ok := psiSection(payload)
+	if !ok || len(section) < 12 {
+		return
+	}
+
+	i := 12 + (int(section[10]&0x0F)<<8 | int(section[11]))
+	for i+5 <= len(section)-4 {
+		streamType := section[i]
+		pid := int(section[i+1]&0x1F)<<8 | int(section[i+2])
+		infoLength := int(section[i+3]&0x0F)<<8 | int(section[i+4])
+
+		switch streamType {
+		case tsStreamTypeADTS, tsStreamTypeMPEG1Audio, tsStreamTypeMPEG2Audio:
+			d.audioPID = pid
+
+			return
+		}
+
+		i += 5 + infoLength
+	}
+}
+
+func tsPayload(packet []byte) ([]byte, bool) {
+	control := (packet[3] >> 4) & 0x03
+	if control&0x01 == 0 {
+		return nil, false
+	}
+
+	offset := 4
+	if control&0x02 != 0 {
+		offset += 1 + int(packet[4])
+	}
+
+	if offset >= len(packet) {
+		return nil, false
+	}
+
+	return packet[offset:], true
+}
+
+func psiSection(payload []byte) ([]byte, bool) {
+	if len(payload) < 1 {
+		return nil, false
+	}
+
+	offset := 1 + int(payload[0])
+	if offset+3 > len(payload) {
+		return nil, false
+	}
+
+	section := payload[offset:]
+	length := 3 + (int(section[1]&0x0F)<<8 | int(section[2]))
+	if length > len(section) {
+		return nil, false
+	}
+
+	return section[:length], true
+}
+
+func pesPayload(payload []byte) []byte {
+	if len(payload) < 9 || payload[0] != 0 || payload[1] != 0 || payload[2] != 1 {
+		return nil
+	}
+
+	offset := 9 + int(payload[8])
+	if offset > len(payload) {
+		return nil
+	}
+
+	return payload[offset:]
+}
@@ -0,0 +1,337 @@
+package radio
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	loudnessBlockDuration = 100 * time.Millisecond
+	loudnessWindowBlocks  = 30
+	loudnessGate          = -50.0
+
+	limiterLookahead = 5 * time.Millisecond
+	limiterRelease   = 100 * time.Millisecond
+
+	// normalizationLearnTime is how long a station is measured before its gain is reported.
+	normalizationLearnTime = 30 * time.Second
+)
+
+// NormalizationPolicy controls the loudness normalization. The short-term
+// loudness (3 s, ITU-R BS.1770) of each station is slowly brought to Target
+// LUFS, within MaxGain dB, and a limiter keeps the true peaks below Ceiling dBTP.
+type NormalizationPolicy struct {
+	Enabled    bool
+	Target     float64
+	MaxGain    float64
+	Ceiling    float64
+	Adaptation time.Duration
+}
+
+func DefaultNormalizationPolicy() NormalizationPolicy {
+	return NormalizationPolicy{
+		Target:     -18,
+		MaxGain:    12,
+		Ceiling:    -1,
+		Adaptation: 20 * time.Second,
+	}
+}
+
+// StreamGainHandler receives the gain learned for a stream, in dB.
+type StreamGainHandler func(stream string, gain float64)
+
+type biquad struct {
+	b0, b1, b2, a1, a2 float64
+	z1, z2             []float64
+}
+
+func (f *biquad) process(channel int, x float64) float64 {
+	y := f.b0*x + f.z1[channel]
+	f.z1[channel] = f.b1*x - f.a1*y + f.z2[channel]
+	f.z2[channel] = f.b2*x - f.a2*y
+
+	return y
+}
+
+// kWeighting returns the BS.1770 pre-filter and RLB high-pass for the sample rate.
+func kWeighting(sampleRate, channels int) (*biquad, *biquad) {
+	k := math.Tan(math.Pi * 1681.974450955533 / float64(sampleRate))
+	q := 0.7071752369554196
+	vh := math.Pow(10, 3.999843853973347/20)
+	vb := math.Pow(vh, 0.4996667741545416)
+	a0 := 1 + k/q + k*k
+
+	shelf := &biquad{
+		b0: (vh + vb*k/q + k*k) / a0,
+		b1: 2 * (k*k - vh) / a0,
+		b2: (vh - vb*k/q + k*k) / a0,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/q + k*k) / a0,
+		z1: make([]float64, channels),
+		z2: make([]float64, channels),
+	}
+
+	k = math.Tan(math.Pi * 38.13547087602444 / float64(sampleRate))
+	q = 0.5003270373238773
+	a0 = 1 + k/q + k*k
+
+	highPass := &biquad{
+		b0: 1,
+		b1: -2,
+		b2: 1,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/q + k*k) / a0,
+		z1: make([]float64, channels),
+		z2: make([]float64, channels),
+	}
+
+	return shelf, highPass
+}
+
+type limiterGain struct {
+	index int
+	gain  float64
+}
+
+// normalizer applies the loudness normalization to S16LE PCM.
+type normalizer struct {
+	reader   io.Reader
+	policy   NormalizationPolicy
+	channels int
+
+	shelf, highPass *biquad
+	blockFrames     int
+	blockFrame      int
+	blockEnergy     float64
+	blocks          []float64
+	measured        time.Duration
+
+	gain      float64
+	linear    float64
+	smoothing float64
+
+	ceiling  float64
+	delay    [][]float64
+	history  [][]float64
+	position int
+	window   []limiterGain
+	envelope float64
+	release  float64
+
+	input   []byte
+	output  []byte
+	pending []byte
+	mu      sync.Mutex
+}
+
+func newNormalizer(reader io.Reader, policy NormalizationPolicy, gain float64, sampleRate, channels int) *normalizer {
+	shelf, highPass := kWeighting(sampleRate, channels)
+	lookahead := int(limiterLookahead.Seconds() * float64(sampleRate))
+
+	n := &normalizer{
+		reader:      reader,
+		policy:      policy,
+		channels:    channels,
+		shelf:       shelf,
+		highPass:    highPass,
+		blockFrames: int(loudnessBlockDuration.Seconds() * float64(sampleRate)),
+		gain:        gain,
+		linear:      math.Pow(10, gain/20),
+		smoothing:   1 - math.Exp(-1/(0.05*float64(sampleRate))),
+		ceiling:     math.Min(math.Pow(10, policy.Ceiling/20), 1),
+		delay:       make([][]float64, lookahead+1),
+		history:     make([][]float64, 4),
+		envelope:    1,
+		release:     1 - math.Exp(-1/(limiterRelease.Seconds()*float64(sampleRate))),
+	}
+
+	for i := range n.delay {
+		n.delay[i] = make([]float64, channels)
+	}
+
+	for i := range n.history {
+		n.history[i] = make([]float64, channels)
+	}
+
+	return n
+}
+
+// Gain returns the current normalization gain in dB and for how long the
+// stream has been measured.
+func (n *normalizer) Gain() (float64, time.Duration) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return n.gain, n.measured
+}
+
+func (n *normalizer) Read(p []byte) (int, error) {
+	frameSize := 2 * n.channels
+
+	for len(n.pending) == 0 {
+		size := len(p) - len(p)%frameSize
+		if size < frameSize {
+			size = frameSize
+		}
+
+		if cap(n.input) < len(n.input)+size {
+			n.input = append(make([]byte, 0, len(n.input)+size), n.input...)
+		}
+
+		m, err := n.reader.Read(n.input[len(n.input) : len(n.input)+size])
+		n.input = n.input[:len(n.input)+m]
+
+		frames := len(n.input) / frameSize
+		if frames > 0 {
+			n.output = append(n.output[:0], n.input[:frames*frameSize]...)
+			n.pending = n.process(n.output)
+			n.input = n.input[:copy(n.input, n.input[frames*frameSize:])]
+		}
+
+		if err != nil {
+			if len(n.pending) > 0 {
+				break
+			}
+
+			return 0, err
+		}
+	}
+
+	c := copy(p, n.pending)
+	n.pending = n.pending[c:]
+
+	return c, nil
+}
+
+// process normalizes the frames in place.
+func (n *normalizer) process(data []byte) []byte {
+	frame := make([]float64, n.channels)
+
+	for i := 0; i+2*n.channels <= len(data); i += 2 * n.channels {
+		for ch := range frame {
+			frame[ch] = float64(int16(binary.LittleEndian.Uint16(data[i+2*ch:]))) / 32768
+		}
+
+		n.measure(frame)
+
+		n.linear += (math.Pow(10, n.gain/20) - n.linear) * n.smoothing
+		for ch := range frame {
+			frame[ch] *= n.linear
+		}
+
+		n.limit(frame)
+
+		for ch, v := range frame {
+			binary.LittleEndian.PutUint16(data[i+2*ch:], uint16(int16(math.Round(v*32767))))
+		}
+	}
+
+	return data
+}
+
+func (n *normalizer) measure(frame []float64) {
+	for ch, x := range frame {
+		y := n.highPass.process(ch, n.shelf.process(ch, x))
+		n.blockEnergy += y * y
+	}
+
+	n.blockFrame++
+	if n.blockFrame < n.blockFrames {
+		return
+	}
+
+	n.blocks = append(n.blocks, n.blockEnergy/float64(n.blockFrames))
+	if len(n.blocks) > loudnessWindowBlocks {
+		n.blocks = n.blocks[1:]
+	}
+
+	n.blockFrame, n.blockEnergy = 0, 0
+
+	if len(n.blocks) < loudnessWindowBlocks {
+		return
+	}
+
+	var energy float64
+	for _, e := range n.blocks {
+		energy += e
+	}
+
+	loudness := -0.691 + 10*math.Log10(energy/float64(len(n.blocks)))
+	if loudness < loudnessGate {
+		return
+	}
+
+	desired := math.Max(-n.policy.MaxGain, math.Min(n.policy.Target-loudness, n.policy.MaxGain))
+
+	rate := 1.0
+	if n.policy.Adaptation > 0 {
+		rate = math.Min(loudnessBlockDuration.Seconds()/n.policy.Adaptation.Seconds(), 1)
+	}
+
+	n.mu.Lock()
+	n.gain += (desired - n.gain) * rate
+	n.measured += loudnessBlockDuration
+	n.mu.Unlock()
+}
+
+// limit delays the frame by the lookahead and replaces it with the delayed
+// one, attenuated enough to keep the peaks below the ceiling. The true peak
+// is estimated by interpolating between the samples.
+func (n *normalizer) limit(frame []float64) {
+	oldest := n.history[0]
+	copy(n.history, n.history[1:])
+	n.history[3] = oldest
+	copy(oldest, frame)
+
+	peak := 0.0
+	for ch := range frame {
+		peak = math.Max(peak, truePeak(n.history[0][ch], n.history[1][ch], n.history[2][ch], n.history[3][ch]))
+	}
+
+	gain := 1.0
+	if peak > n.ceiling {
+		gain = n.ceiling / peak
+	}
+
+	index := n.position
+	n.position++
+
+	for len(n.window) > 0 && n.window[len(n.window)-1].gain >= gain {
+		n.window = n.window[:len(n.window)-1]
+	}
+
+	n.window = append(n.window, limiterGain{index: index, gain: gain})
+	for n.window[0].index <= index-len(n.delay) {
+		n.window = n.window[1:]
+	}
+
+	target := n.window[0].gain
+	if target < n.envelope {
+		n.envelope = target
+	} else {
+		n.envelope += (target - n.envelope) * n.release
+	}
+
+	slot := n.delay[index%len(n.delay)]
+	for ch := range frame {
+		delayed := slot[ch]
+		slot[ch] = frame[ch]
+		frame[ch] = math.Max(-n.ceiling, math.Min(delayed*n.envelope, n.ceiling))
+	}
+}
+
+// truePeak estimates the peak between x1 and x2 with a Catmull-Rom spline at
+// four times the sample rate.
+func truePeak(x0, x1, x2, x3 float64) float64 {
+	peak := math.Max(math.Abs(x1), math.Abs(x2))
+
+	for _, t := range [...]float64{0.25, 0.5, 0.75} {
+		v := 0.5 * (2*x1 + (-x0+x2)*t + (2*x0-5*x1+4*x2-x3)*t*t + (-x0+3*x1-3*x2+x3)*t*t*t)
+		peak = math.Max(peak, math.Abs(v))
+	}
+
+	return peak
+}
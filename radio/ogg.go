@@ -0,0 +1,328 @@
+package radio
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+const (
+	oggHeaderSize  = 27
+	oggMaxPageSize = oggHeaderSize + 255 + 255*255
+
+	oggFlagContinued = 0x01
+	oggFlagBOS       = 0x02
+	oggFlagEOS       = 0x04
+)
+
+var oggCRCTable = func() [256]uint32 {
+	var table [256]uint32
+
+	for i := range table {
+		r := uint32(i) << 24
+		for j := 0; j < 8; j++ {
+			if r&0x80000000 != 0 {
+				r = r<<1 ^ 0x04C11DB7
+			} else {
+				r <<= 1
+			}
+		}
+
+		table[i] = r
+	}
+
+	return table
+}()
+
+type oggPacket struct {
+	data   []byte
+	serial uint32
+	bos    bool
+	eos    bool
+}
+
+// oggReader demultiplexes Ogg pages into packets of every logical bitstream,
+// resynchronizing on the capture pattern after corrupted or truncated pages.
+type oggReader struct {
+	reader  *bufio.Reader
+	partial map[uint32][]byte
+	packets []oggPacket
+}
+
+func newOggReader(r io.Reader) *oggReader {
+	return &oggReader{
+		reader:  bufio.NewReaderSize(r, oggMaxPageSize),
+		partial: make(map[uint32][]byte),
+	}
+}
+
+func (r *oggReader) ReadPacket() (oggPacket, error) {
+	for len(r.packets) == 0 {
+		err := r.readPage()
+		if err != nil {
+			return oggPacket{}, err
+		}
+	}
+
+	packet := r.packets[0]
+	r.packets = r.packets[1:]
+
+	return packet, nil
+}
+
+func (r *oggReader) readPage() error {
+	page, err := r.nextPage()
+	if err != nil {
+		return err
+	}
+
+	flags := page[5]
+	serial := binary.LittleEndian.Uint32(page[14:])
+	segments := page[oggHeaderSize : oggHeaderSize+int(page[26])]
+	data := page[oggHeaderSize+len(segments):]
+
+	partial, ok := r.partial[serial]
+	if flags&oggFlagContinued == 0 {
+		partial = nil
+	} else if !ok {
+		// Joined in the middle of a packet, the first one on the page is incomplete.
+		partial = nil
+		for len(segments) > 0 {
+			lace := int(segments[0])
+			segments = segments[1:]
+			data = data[lace:]
+
+			if lace < 255 {
+				break
+			}
+		}
+	}
+
+	bos := flags&oggFlagBOS != 0
+
+	for _, lace := range segments {
+		partial = append(partial, data[:lace]...)
+		data = data[lace:]
+
+		if lace < 255 {
+			r.packets = append(r.packets, oggPacket{data: partial, serial: serial, bos: bos})
+			partial = nil
+			bos = false
+		}
+	}
+
+	if flags&oggFlagEOS != 0 {
+		if len(r.packets) > 0 {
+			r.packets[len(r.packets)-1].eos = true
+		}
+
+		delete(r.partial, serial)
+
+		return nil
+	}
+
+	if len(segments) > 0 && segments[len(segments)-1] == 255 {
+		r.partial[serial] = partial
+	} else {
+		delete(r.partial, serial)
+	}
+
+	return nil
+}
+
+func (r *oggReader) nextPage() ([]byte, error) {
+	for {
+		header, err := r.reader.Peek(oggHeaderSize)
+		if err != nil {
+			return nil, err
+		}
+
+		if string(header[:4]) != "OggS" || header[4] != 0 {
+			_, _ = r.reader.Discard(1)
+
+			continue
+		}
+
+		table, err := r.reader.Peek(oggHeaderSize + int(header[26]))
+		if err != nil {
+			return nil, err
+		}
+
+		size := len(table)
+		for _, lace := range table[oggHeaderSize:] {
+			size += int(lace)
+		}
+
+		page, err := r.reader.Peek(size)
+		if err != nil {
+			return nil, err
+		}
+
+		if oggChecksum(page) != binary.LittleEndian.Uint32(page[22:]) {
+			_, _ = r.reader.Discard(1)
+
+			continue
+		}
+
+		page = append([]byte(nil), page...)
+		_, _ = r.reader.Discard(size)
+
+		return page, nil
+	}
+}
+
+func oggChecksum(page []byte) uint32 {
+	var crc uint32
+
+	for i, b := range page {
+		// The checksum field itself is computed as zeros.
+		if i >= 22 && i < 26 {
+			b = 0
+		}
+
+		crc = crc<<8 ^ oggCRCTable[byte(crc>>24)^b]
+	}
+
+	return crc
+}
+
+// oggHeaderCodecs are how many header packets start the bitstreams of the
+// codecs. The bitstreams of other codecs only need their first page.
+var oggHeaderCodecs = []struct {
+	magic   []byte
+	packets int
+}{
+	{magic: []byte("\x01vorbis"), packets: 3},
+	{magic: []byte("OpusHead"), packets: 2},
+}
+
+// oggPages splits the raw data of an Ogg stream into whole pages and keeps
+// the header pages of its logical bitstreams, so the stream can be decoded
+// from any page. A chained stream starts new bitstreams on every track
+// change, their headers replace the previous ones.
+type oggPages struct {
+	pending []byte
+	headers []byte
+	missing map[uint32]int
+	data    bool
+}
+
+func newOggPages() *oggPages {
+	return &oggPages{missing: make(map[uint32]int)}
+}
+
+// Write returns the whole pages completed by the data, along with the header
+// pages needed to decode them.
+func (o *oggPages) Write(data []byte) (pages, headers []byte) {
+	headers = o.headers[:len(o.headers):len(o.headers)]
+	o.pending = append(o.pending, data...)
+
+	for {
+		i := bytes.Index(o.pending, []byte("OggS"))
+		if i < 0 {
+			// The capture pattern may be cut by the end of the data.
+			if len(o.pending) > 3 {
+				o.pending = o.pending[len(o.pending)-3:]
+			}
+
+			break
+		}
+
+		o.pending = o.pending[i:]
+
+		size, ok := oggPageSize(o.pending)
+		if !ok {
+			break
+		}
+
+		if size < 0 {
+			o.pending = o.pending[1:]
+
+			continue
+		}
+
+		page := o.pending[:size]
+		o.pending = o.pending[size:]
+
+		pages = append(pages, page...)
+		o.keep(page)
+	}
+
+	o.pending = append([]byte(nil), o.pending...)
+
+	return pages, headers
+}
+
+// keep adds the page to the headers if it belongs to them.
+func (o *oggPages) keep(page []byte) {
+	serial := binary.LittleEndian.Uint32(page[14:])
+
+	if page[5]&oggFlagBOS != 0 {
+		// The bitstreams of a stream all start before its data.
+		if o.data {
+			o.headers, o.missing, o.data = nil, make(map[uint32]int), false
+		}
+
+		o.missing[serial] = 1
+
+		first := page[oggHeaderSize+int(page[26]):]
+		for _, c := range oggHeaderCodecs {
+			if bytes.HasPrefix(first, c.magic) {
+				o.missing[serial] = c.packets
+			}
+		}
+	}
+
+	missing, ok := o.missing[serial]
+	if !ok {
+		o.data = true
+
+		return
+	}
+
+	o.headers = append(o.headers, page...)
+
+	for _, lace := range page[oggHeaderSize : oggHeaderSize+int(page[26])] {
+		if lace < 255 {
+			missing--
+		}
+	}
+
+	if missing > 0 {
+		o.missing[serial] = missing
+	} else {
+		delete(o.missing, serial)
+	}
+}
+
+// oggPageSize returns the size of the page at the start of the data, -1 if
+// it is not a valid page, or false if the data does not hold all of it.
+func oggPageSize(data []byte) (int, bool) {
+	if len(data) < oggHeaderSize {
+		return 0, false
+	}
+
+	if data[4] != 0 {
+		return -1, true
+	}
+
+	size := oggHeaderSize + int(data[26])
+	if len(data) < size {
+		return 0, false
+	}
+
+	for _, lace := range data[oggHeaderSize:size] {
+		size += int(lace)
+	}
+
+	if len(data) < size {
+		return 0, false
+	}
+
+	if oggChecksum(data[:size]) != binary.LittleEndian.Uint32(data[22:]) {
+		return -1, true
+	}
+
+	return size, true
+}
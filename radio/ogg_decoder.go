@@ -0,0 +1,219 @@
+package radio
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"sync"
+)
+
+const (
+	oggMaxHeaderPackets = 64
+)
+
+// oggCodec decodes the packets of a single logical Ogg bitstream.
+// Decode returns no samples for header packets.
+type oggCodec interface {
+	Decode(packet []byte) ([]byte, error)
+	SampleRate() int
+	Channels() int
+	Bitrate() int
+	Close() error
+}
+
+type oggCodecFactory func(header []byte) (oggCodec, error)
+
+var oggCodecs = []struct {
+	magic []byte
+	new   oggCodecFactory
+}{
+	{magic: []byte("\x01vorbis"), new: newVorbisCodec},
+	{magic: []byte("OpusHead"), new: newOpusCodec},
+}
+
+// oggDecoder plays Vorbis and Opus streams, including chained streams where
+// the server starts a new logical bitstream (possibly with another codec or
+// format) on every track change. The codec is guarded by the mutex, since
+// the decoder may be closed while it is being read.
+type oggDecoder struct {
+	reader *oggReader
+	serial uint32
+	codec  oggCodec
+	pcm    []byte
+	closed bool
+	mu     sync.Mutex
+}
+
+func OggFormat() DecoderFormat {
+	return DecoderFormat{
+		Name:         "ogg",
+		ContentTypes: []string{"application/ogg", "audio/ogg", "audio/vorbis", "audio/opus", "audio/x-ogg"},
+		Sniff:        sniffOgg,
+		New:          newOggDecoder,
+	}
+}
+
+func newOggDecoder(r io.Reader) (Decoder, error) {
+	d := &oggDecoder{reader: newOggReader(r)}
+
+	for i := 0; len(d.pcm) == 0; i++ {
+		if i >= oggMaxHeaderPackets {
+			_ = d.Close()
+
+			return nil, errors.New("cannot start decoding")
+		}
+
+		err := d.decodePacket()
+		if err != nil {
+			_ = d.Close()
+
+			return nil, err
+		}
+	}
+
+	return d, nil
+}
+
+func (d *oggDecoder) Read(p []byte) (int, error) {
+	for {
+		d.mu.Lock()
+
+		if d.closed {
+			d.mu.Unlock()
+
+			return 0, io.ErrClosedPipe
+		}
+
+		if len(d.pcm) > 0 {
+			n := copy(p, d.pcm)
+			d.pcm = d.pcm[n:]
+			d.mu.Unlock()
+
+			return n, nil
+		}
+
+		d.mu.Unlock()
+
+		err := d.decodePacket()
+		if err != nil {
+			return 0, err
+		}
+	}
+}
+
+func (d *oggDecoder) SampleRate() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.codec == nil {
+		return 0
+	}
+
+	return d.codec.SampleRate()
+}
+
+func (d *oggDecoder) Channels() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.codec == nil {
+		return 0
+	}
+
+	return d.codec.Channels()
+}
+
+func (d *oggDecoder) Bitrate() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.codec == nil {
+		return 0
+	}
+
+	return d.codec.Bitrate()
+}
+
+func (d *oggDecoder) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.closed = true
+
+	if d.codec == nil {
+		return nil
+	}
+
+	err := d.codec.Close()
+	d.codec = nil
+
+	return err
+}
+
+// decodePacket reads the next packet, then decodes it with the mutex held.
+func (d *oggDecoder) decodePacket() error {
+	packet, err := d.reader.ReadPacket()
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed {
+		return io.ErrClosedPipe
+	}
+
+	if packet.bos {
+		return d.begin(packet)
+	}
+
+	if d.codec == nil || packet.serial != d.serial {
+		return nil
+	}
+
+	pcm, err := d.codec.Decode(packet.data)
+	if err != nil {
+		return err
+	}
+
+	d.pcm = pcm
+
+	return nil
+}
+
+// begin switches to a new logical bitstream and must be called with the mutex
+// held. Streams with unknown codecs (e.g. multiplexed metadata or video) are
+// ignored.
+func (d *oggDecoder) begin(packet oggPacket) error {
+	for _, c := range oggCodecs {
+		if !bytes.HasPrefix(packet.data, c.magic) {
+			continue
+		}
+
+		codec, err := c.new(packet.data)
+		if err != nil {
+			return err
+		}
+
+		if d.codec != nil {
+			err = d.codec.Close()
+			if err != nil {
+				_ = codec.Close()
+
+				return err
+			}
+		}
+
+		d.codec = codec
+		d.serial = packet.serial
+
+		return nil
+	}
+
+	return nil
+}
+
+func sniffOgg(header []byte) bool {
+	return bytes.HasPrefix(header, []byte("OggS"))
+}
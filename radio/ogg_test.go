@@ -0,0 +1,93 @@
+package radio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// oggTestPage builds a page holding whole packets of the bitstream.
+func oggTestPage(serial uint32, flags byte, packets ...[]byte) []byte {
+	var laces, data []byte
+
+	for _, packet := range packets {
+		size := len(packet)
+		for ; size >= 255; size -= 255 {
+			laces = append(laces, 255)
+		}
+
+		laces = append(laces, byte(size))
+		data = append(data, packet...)
+	}
+
+	page := make([]byte, oggHeaderSize, oggHeaderSize+len(laces)+len(data))
+	copy(page, "OggS")
+	page[5] = flags
+	binary.LittleEndian.PutUint32(page[14:], serial)
+	page[26] = byte(len(laces))
+	page = append(append(page, laces...), data...)
+	binary.LittleEndian.PutUint32(page[22:], oggChecksum(page))
+
+	return page
+}
+
+func TestOggPagesFollowChainedHeaders(t *testing.T) {
+	first := [][]byte{
+		oggTestPage(1, oggFlagBOS, []byte("\x01vorbis-id")),
+		oggTestPage(1, 0, []byte("\x03vorbis-comment"), bytes.Repeat([]byte{5}, 600)),
+	}
+	second := [][]byte{
+		oggTestPage(2, oggFlagBOS, []byte("OpusHead")),
+		oggTestPage(2, 0, []byte("OpusTags")),
+	}
+
+	var stream []byte
+	for _, page := range [][]byte{
+		first[0],
+		first[1],
+		oggTestPage(1, 0, []byte("audio-1")),
+		oggTestPage(1, oggFlagEOS, []byte("audio-2")),
+		second[0],
+		second[1],
+		oggTestPage(2, 0, []byte("audio-3")),
+	} {
+		stream = append(stream, page...)
+	}
+
+	// Garbage before the stream is skipped.
+	stream = append([]byte("OggS garbage"), stream...)
+
+	o := newOggPages()
+
+	var pages []byte
+
+	for i := 0; i < len(stream); i += 7 {
+		end := i + 7
+		if end > len(stream) {
+			end = len(stream)
+		}
+
+		chunk, _ := o.Write(stream[i:end])
+		if len(chunk) > 0 && !bytes.HasPrefix(chunk, []byte("OggS")) {
+			t.Fatalf("chunk %d does not start with a page", i)
+		}
+
+		pages = append(pages, chunk...)
+	}
+
+	if want := stream[len("OggS garbage"):]; !bytes.Equal(pages, want) {
+		t.Fatalf("got %d bytes of pages, want %d", len(pages), len(want))
+	}
+
+	if want := append(append([]byte(nil), second[0]...), second[1]...); !bytes.Equal(o.headers, want) {
+		t.Fatalf("got headers %q, want the second bitstream %q", o.headers, want)
+	}
+
+	// The headers of the first bitstream are complete once its data starts.
+	o = newOggPages()
+	o.Write(append(append(append([]byte(nil), first[0]...), first[1]...), oggTestPage(1, 0, []byte("audio"))...))
+
+	if want := append(append([]byte(nil), first[0]...), first[1]...); !bytes.Equal(o.headers, want) || len(o.missing) != 0 {
+		t.Fatalf("got headers %q (missing: %v), want the first bitstream", o.headers, o.missing)
+	}
+}
@@ -0,0 +1,155 @@
+package radio
+
+/*
+#cgo LDFLAGS: -lopus -lm
+
+#include <opus/opus.h>
+
+static int opus_set_gain(OpusDecoder *decoder, int gain) {
+	return opus_decoder_ctl(decoder, OPUS_SET_GAIN(gain));
+}
+*/
+import "C"
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"unsafe"
+)
+
+const (
+	opusSampleRate   = 48000
+	opusMaxFrameSize = 5760
+	opusHeaderSize   = 19
+)
+
+// opusCodec decodes mono and stereo Ogg Opus streams with libopus. The
+// decoder is guarded by the mutex, so it is never destroyed while decoding.
+type opusCodec struct {
+	decoder  *C.OpusDecoder
+	channels int
+	preSkip  int
+	buffer   []int16
+	bytes    int
+	samples  int
+	mu       sync.Mutex
+}
+
+func newOpusCodec(header []byte) (oggCodec, error) {
+	if len(header) < opusHeaderSize {
+		return nil, errors.New("opus: invalid header")
+	}
+
+	channels := int(header[9])
+	preSkip := int(binary.LittleEndian.Uint16(header[10:]))
+	gain := int(int16(binary.LittleEndian.Uint16(header[16:])))
+	mappingFamily := header[18]
+
+	if channels < 1 || channels > 2 || mappingFamily > 1 {
+		return nil, fmt.Errorf("opus: unsupported channel mapping (family: %d, channels: %d)", mappingFamily, channels)
+	}
+
+	var code C.int
+
+	decoder := C.opus_decoder_create(opusSampleRate, C.int(channels), &code)
+	if code != C.OPUS_OK {
+		return nil, fmt.Errorf("opus: %s", C.GoString(C.opus_strerror(code)))
+	}
+
+	if gain != 0 {
+		code = C.opus_set_gain(decoder, C.int(gain))
+		if code != C.OPUS_OK {
+			C.opus_decoder_destroy(decoder)
+
+			return nil, fmt.Errorf("opus: %s", C.GoString(C.opus_strerror(code)))
+		}
+	}
+
+	return &opusCodec{
+		decoder:  decoder,
+		channels: channels,
+		preSkip:  preSkip,
+		buffer:   make([]int16, opusMaxFrameSize*channels),
+	}, nil
+}
+
+func (c *opusCodec) Decode(packet []byte) ([]byte, error) {
+	if len(packet) == 0 || bytes.HasPrefix(packet, []byte("OpusTags")) {
+		return nil, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.decoder == nil {
+		return nil, errors.New("opus: decoder is closed")
+	}
+
+	n := C.opus_decode(
+		c.decoder,
+		(*C.uchar)(unsafe.Pointer(&packet[0])),
+		C.opus_int32(len(packet)),
+		(*C.opus_int16)(unsafe.Pointer(&c.buffer[0])),
+		opusMaxFrameSize,
+		0,
+	)
+	if n < 0 {
+		return nil, fmt.Errorf("opus: %s", C.GoString(C.opus_strerror(n)))
+	}
+
+	c.bytes += len(packet)
+	c.samples += int(n)
+
+	samples := c.buffer[:int(n)*c.channels]
+
+	if c.preSkip > 0 {
+		skip := c.preSkip
+		if skip > int(n) {
+			skip = int(n)
+		}
+
+		c.preSkip -= skip
+		samples = samples[skip*c.channels:]
+	}
+
+	pcm := make([]byte, len(samples)*2)
+	for i, sample := range samples {
+		binary.LittleEndian.PutUint16(pcm[2*i:], uint16(sample))
+	}
+
+	return pcm, nil
+}
+
+func (c *opusCodec) SampleRate() int {
+	return opusSampleRate
+}
+
+func (c *opusCodec) Channels() int {
+	return c.channels
+}
+
+func (c *opusCodec) Bitrate() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.samples == 0 {
+		return 0
+	}
+
+	return c.bytes * 8 * opusSampleRate / (c.samples * 1000)
+}
+
+func (c *opusCodec) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.decoder != nil {
+		C.opus_decoder_destroy(c.decoder)
+		c.decoder = nil
+	}
+
+	return nil
+}
@@ -0,0 +1,69 @@
+package radio
+
+import (
+	"io"
+	"sync"
+
+	"github.com/hajimehoshi/oto/v2"
+)
+
+// otoSink plays to the default sound card. The oto context is created on the
+// first Resume, as there can be only one per process. Check for more details
+// https://github.com/hajimehoshi/oto/issues/149
+type otoSink struct {
+	context *oto.Context
+	mu      sync.Mutex
+}
+
+func NewOtoSink() Sink {
+	return &otoSink{}
+}
+
+func (s *otoSink) NewStream(source io.Reader) SinkStream {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.context.NewPlayer(source)
+}
+
+func (s *otoSink) Resume() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.context != nil {
+		return s.context.Resume()
+	}
+
+	context, ready, err := oto.NewContext(contextSampleRate, contextNumChannels, 2)
+	if err != nil {
+		return err
+	}
+
+	<-ready
+
+	s.context = context
+
+	return nil
+}
+
+func (s *otoSink) Suspend() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.context == nil {
+		return nil
+	}
+
+	return s.context.Suspend()
+}
+
+func (s *otoSink) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.context == nil {
+		return nil
+	}
+
+	return s.context.Err()
+}
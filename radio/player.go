@@ -5,39 +5,93 @@ import (
 	"fmt"
 	"io"
 	"log"
-	"net/http"
+	"math"
+	"sync"
 	"time"
-
-	"github.com/hajimehoshi/oto/v2"
-	"github.com/tosone/minimp3"
 )
 
 const (
 	contextSampleRate  = 44100
 	contextNumChannels = 2
+
+	crossfadeStep         = 20 * time.Millisecond
+	crossfadeReadyTimeout = 10 * time.Second
+
+	volumeRampStep = 20 * time.Millisecond
 )
 
 type ErrorHandler func(err error)
 
+type MetadataHandler func(metadata Metadata)
+
+// StreamHandler receives the number of the stream the player switched to,
+// starting from 1, once it plays: a switch asked for by Next or Prev, or the
+// watchdog skipping a station.
+type StreamHandler func(streamNum int)
+
 type Player struct {
-	streams      []string
-	stream       io.ReadCloser
-	decoder      *minimp3.Decoder
-	context      *oto.Context
-	player       oto.Player
-	volume       float64
-	errorHandler ErrorHandler
-	index        int
-	play         chan string
-	stop         chan struct{}
+	streams         []string
+	conn            *connection
+	decoders        *DecoderRegistry
+	sink            Sink
+	player          SinkStream
+	volume          float64
+	level           float64
+	ramp            int
+	crossfading     bool
+	state           State
+	reconnectPolicy ReconnectPolicy
+	watchdogPolicy  WatchdogPolicy
+	bufferPolicy    BufferPolicy
+	crossfade       time.Duration
+	powerFade       time.Duration
+	normalization   NormalizationPolicy
+	streamGains     map[string]float64
+	recorder        *recorder
+	replay          *replayBuffer
+	replayPolicy    ReplayPolicy
+	relay           *relay
+	filters         []FilterSpec
+	streamFilters   map[string][]FilterSpec
+	compressor      CompressorPolicy
+	nightMode       bool
+	errorHandler    ErrorHandler
+	metadataHandler MetadataHandler
+	stateHandler    StateHandler
+	watchdogHandler WatchdogHandler
+	gainHandler     StreamGainHandler
+	streamHandler   StreamHandler
+	underruns       int
+	index           int
+	target          int
+	play            chan int
+	stop            chan struct{}
+	done            chan struct{}
+	watchSink       sync.Once
+	mu              sync.Mutex
 }
 
 func NewPlayer(streams ...string) *Player {
 	return &Player{
-		streams: streams,
-		volume:  1,
-		play:    make(chan string),
-		stop:    make(chan struct{}),
+		streams:         streams,
+		decoders:        DefaultDecoderRegistry(),
+		sink:            NewOtoSink(),
+		volume:          1,
+		level:           1,
+		state:           StateStopped,
+		reconnectPolicy: DefaultReconnectPolicy(),
+		watchdogPolicy:  DefaultWatchdogPolicy(),
+		bufferPolicy:    DefaultBufferPolicy(),
+		crossfade:       2 * time.Second,
+		powerFade:       time.Second,
+		normalization:   DefaultNormalizationPolicy(),
+		streamGains:     make(map[string]float64),
+		streamFilters:   make(map[string][]FilterSpec),
+		compressor:      DefaultCompressorPolicy(),
+		replayPolicy:    DefaultReplayPolicy(),
+		relay:           newRelay(),
+		play:            make(chan int, 1),
+		stop:            make(chan struct{}),
 		errorHandler: func(err error) {
 			log.Printf("An error occured while playing/stopping: %s\n", err)
 		},
@@ -58,9 +112,28 @@ func (p *Player) Play(streamNum int) {
 		index = 0
 	}
 
+	done := make(chan struct{})
+
+	p.mu.Lock()
+	p.done = done
+	p.mu.Unlock()
+
+	// A switch asked for before the last stop is stale.
+	select {
+	case <-p.play:
+	default:
+	}
+
+	p.setState(StateEvent{State: StateConnecting, Stream: p.exactStream(index)})
+
 	go func() {
+		defer close(done)
+		defer p.relay.detach()
+
 		defer func() {
 			if r := recover(); r != nil {
+				p.setState(StateEvent{State: StateFailed, Stream: p.currentStream()})
+
 				if v, ok := r.(error); ok {
 					p.errorHandler(v)
 				} else {
@@ -69,7 +142,7 @@ func (p *Player) Play(streamNum int) {
 			}
 		}()
 
-		err := p.run(index)
+		err := p.run(p.currentStream())
 		if err != nil {
 			p.errorHandler(err)
 		}
@@ -78,203 +151,1031 @@ func (p *Player) Play(streamNum int) {
 	return
 }
 
+// Prev switches to the previous stream and returns its number. The stream
+// playing stays the current one until the other one plays, and goes on if the
+// other one cannot be connected to.
 func (p *Player) Prev() int {
 	if !p.IsPlaying() {
-		return p.index + 1
+		return p.streamNum()
 	}
 
-	p.play <- p.prevStream()
+	index := p.moveTarget(-1)
+	p.switchStream(index)
 
-	return p.index + 1
+	return index + 1
 }
 
+// Next switches to the next stream and returns its number, like Prev.
 func (p *Player) Next() int {
 	if !p.IsPlaying() {
-		return p.index + 1
+		return p.streamNum()
 	}
 
-	p.play <- p.nextStream()
+	index := p.moveTarget(1)
+	p.switchStream(index)
 
-	return p.index + 1
+	return index + 1
 }
 
+// IsPlaying reports whether the radio is on, including the time spent
+// reconnecting to the station.
 func (p *Player) IsPlaying() bool {
-	return p.player != nil && p.player.IsPlaying()
+	state := p.State()
+
+	return state != StateStopped && state != StateFailed
+}
+
+func (p *Player) State() State {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.state
 }
 
 func (p *Player) OnError(handler ErrorHandler) {
 	p.errorHandler = handler
 }
 
-func (p *Player) Volume() float64 {
-	if p.IsPlaying() {
-		return p.player.Volume()
+func (p *Player) OnStateChange(handler StateHandler) {
+	p.stateHandler = handler
+}
+
+func (p *Player) SetReconnectPolicy(policy ReconnectPolicy) {
+	p.reconnectPolicy = policy
+}
+
+func (p *Player) SetWatchdogPolicy(policy WatchdogPolicy) {
+	p.watchdogPolicy = policy
+}
+
+func (p *Player) SetBufferPolicy(policy BufferPolicy) {
+	p.bufferPolicy = policy
+}
+
+// SetSink replaces the default sound card output, it must be called before playing.
+func (p *Player) SetSink(sink Sink) {
+	p.sink = sink
+}
+
+// SetCrossfade sets how long the previous station fades out while the next
+// one fades in when switching streams. Zero switches without a fade.
+func (p *Player) SetCrossfade(duration time.Duration) {
+	p.crossfade = duration
+}
+
+// SetPowerFade sets how long the playback fades in when it starts and fades
+// out before it stops. Zero starts and stops instantly.
+func (p *Player) SetPowerFade(duration time.Duration) {
+	p.powerFade = duration
+}
+
+func (p *Player) SetNormalization(policy NormalizationPolicy) {
+	p.normalization = policy
+}
+
+// SetStreamGain sets the normalization gain (dB) the stream starts with.
+func (p *Player) SetStreamGain(stream string, gain float64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.streamGains[stream] = gain
+}
+
+// OnStreamGain registers a handler for the normalization gains learned for
+// the streams, reported when they stop playing.
+func (p *Player) OnStreamGain(handler StreamGainHandler) {
+	p.gainHandler = handler
+}
+
+// OnStreamChange registers a handler for the streams the player switches to,
+// once they play.
+func (p *Player) OnStreamChange(handler StreamHandler) {
+	p.streamHandler = handler
+}
+
+// SetFilters sets the DSP chain applied to all the streams, before the
+// filters of the stream. It applies to the current stream at once.
+func (p *Player) SetFilters(filters ...FilterSpec) error {
+	_, err := newFilters(filters, contextSampleRate, contextNumChannels)
+	if err != nil {
+		return err
 	}
 
-	return p.volume
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.filters = filters
+	p.updateFilters()
+
+	return nil
 }
 
-func (p *Player) SetVolume(v float64) {
-	if p.IsPlaying() {
-		p.player.SetVolume(v)
+// SetStreamFilters sets the DSP chain of the stream, applied after the
+// global one.
+func (p *Player) SetStreamFilters(stream string, filters ...FilterSpec) error {
+	_, err := newFilters(filters, contextSampleRate, contextNumChannels)
+	if err != nil {
+		return err
 	}
 
-	p.volume = v
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.streamFilters[stream] = filters
+	if p.conn != nil && p.conn.stream == stream {
+		p.updateFilters()
+	}
+
+	return nil
 }
 
-func (p *Player) Stop() {
-	if !p.IsPlaying() {
+func (p *Player) SetCompressor(policy CompressorPolicy) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.compressor = policy
+	if p.nightMode {
+		p.updateFilters()
+	}
+}
+
+// SetNightMode turns the compressor on or off, after the DSP chain.
+func (p *Player) SetNightMode(enabled bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.nightMode == enabled {
+		return
+	}
+
+	p.nightMode = enabled
+	p.updateFilters()
+}
+
+func (p *Player) NightMode() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.nightMode
+}
+
+// updateFilters must be called with the mutex held.
+func (p *Player) updateFilters() {
+	if p.conn == nil || p.conn.dsp == nil {
+		return
+	}
+
+	filters, err := p.streamChain(p.conn.stream)
+	if err != nil {
+		log.Printf("Cannot update the DSP chain: %s\n", err)
+
 		return
 	}
 
-	p.stop <- struct{}{}
+	p.conn.dsp.SetFilters(filters)
+}
+
+// streamChain must be called with the mutex held.
+func (p *Player) streamChain(stream string) ([]Filter, error) {
+	specs := append(append([]FilterSpec{}, p.filters...), p.streamFilters[stream]...)
+
+	filters, err := newFilters(specs, contextSampleRate, contextNumChannels)
+	if err != nil {
+		return nil, err
+	}
 
+	if p.nightMode {
+		filters = append(filters, newCompressor(p.compressor, contextSampleRate, contextNumChannels))
+	}
+
+	return filters, nil
+}
+
+// Levels reports the levels of the audio of the current stream going to the
+// sink, at the volume it is played at.
+func (p *Player) Levels() Levels {
+	p.mu.Lock()
+	conn, player := p.conn, p.player
+	p.mu.Unlock()
+
+	if conn == nil || conn.meter == nil {
+		return silentLevels(contextNumChannels)
+	}
+
+	return conn.meter.Levels(player.Volume())
+}
+
+// Pause silences the output but keeps receiving the stream, so Resume goes
+// on where it was paused, up to the time shift of the buffer policy.
+func (p *Player) Pause() error {
+	buffer, err := p.timeShiftBuffer()
+	if err != nil {
+		return err
+	}
+
+	buffer.Pause()
+
+	return nil
+}
+
+func (p *Player) Resume() error {
+	buffer, err := p.timeShiftBuffer()
+	if err != nil {
+		return err
+	}
+
+	buffer.Resume()
+
+	return nil
+}
+
+// GoLive skips the audio kept by the pauses and plays the live stream.
+func (p *Player) GoLive() error {
+	buffer, err := p.timeShiftBuffer()
+	if err != nil {
+		return err
+	}
+
+	buffer.GoLive()
+
+	return nil
+}
+
+func (p *Player) timeShiftBuffer() (*jitterBuffer, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.conn == nil {
+		return nil, ErrNotPlaying
+	}
+
+	if p.conn.buffer == nil || p.bufferPolicy.TimeShift <= 0 {
+		return nil, ErrNoTimeShift
+	}
+
+	return p.conn.buffer, nil
+}
+
+// BufferStats reports the buffer of the current stream, the underruns are
+// counted since the player was created.
+func (p *Player) BufferStats() BufferStats {
+	p.mu.Lock()
+	conn, underruns := p.conn, p.underruns
+	p.mu.Unlock()
+
+	var stats BufferStats
+	if conn != nil {
+		stats = conn.BufferStats()
+	}
+
+	stats.Underruns += underruns
+
+	return stats
+}
+
+func (p *Player) OnWatchdog(handler WatchdogHandler) {
+	p.watchdogHandler = handler
+}
+
+func (p *Player) OnMetadata(handler MetadataHandler) {
+	p.metadataHandler = handler
+}
+
+// Metadata describes what is on air: the station headers and the current
+// stream title, if the server sends ICY metadata.
+func (p *Player) Metadata() Metadata {
+	p.mu.Lock()
+	conn, index := p.conn, p.index
+	p.mu.Unlock()
+
+	if conn == nil {
+		return Metadata{}
+	}
+
+	metadata := conn.Metadata()
+	metadata.Stream = index + 1
+
+	return metadata
+}
+
+func (p *Player) RegisterDecoder(format DecoderFormat) {
+	p.decoders.Register(format)
+}
+
+func (p *Player) Volume() float64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.volume
+}
+
+func (p *Player) SetVolume(v float64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.volume = v
+	p.ramp++
+	p.setLevel(v)
+}
+
+// FadeVolume changes the volume gradually to v over the duration. It returns
+// at once, a later volume change takes over from the current level.
+func (p *Player) FadeVolume(v float64, duration time.Duration) {
+	p.mu.Lock()
+	p.volume = v
+	p.mu.Unlock()
+
+	p.rampLevel(v, duration)
+}
+
+// rampLevel moves the output level to the target over the duration, the
+// returned channel is closed when it is reached or another ramp takes over.
+func (p *Player) rampLevel(target float64, duration time.Duration) <-chan struct{} {
+	done := make(chan struct{})
+
+	p.mu.Lock()
+	p.ramp++
+	ramp, from := p.ramp, p.level
+	p.mu.Unlock()
+
+	go func() {
+		defer close(done)
+
+		start := time.Now()
+
+		for {
+			x := 1.0
+			if duration > 0 {
+				x = math.Min(float64(time.Since(start))/float64(duration), 1)
+			}
+
+			p.mu.Lock()
+			if p.ramp != ramp {
+				p.mu.Unlock()
+
+				return
+			}
+
+			p.setLevel(from + (target-from)*x)
+			p.mu.Unlock()
+
+			if x >= 1 {
+				return
+			}
+
+			time.Sleep(volumeRampStep)
+		}
+	}()
+
+	return done
+}
+
+// setLevel applies the output level, unless a crossfade controls the gains.
+// The mutex must be held.
+func (p *Player) setLevel(level float64) {
+	p.level = level
+
+	if p.player != nil && !p.crossfading {
+		p.player.SetVolume(level)
+	}
+}
+
+func (p *Player) Stop() {
 	err := p.Close()
 	if err != nil {
 		p.errorHandler(err)
 	}
 }
 
+// Close stops the playback and waits until the stream is released. The
+// recordings staying on their station go on.
 func (p *Player) Close() error {
-	return p.free()
+	p.stopFollowing()
+
+	if !p.IsPlaying() {
+		return nil
+	}
+
+	// A stream is heard in other states too, like while switching streams.
+	p.mu.Lock()
+	outputting := p.player != nil
+	p.mu.Unlock()
+
+	if outputting {
+		<-p.rampLevel(0, p.powerFade)
+	}
+
+	done := p.runDone()
+
+	select {
+	case p.stop <- struct{}{}:
+		<-done
+	case <-done:
+	}
+
+	return nil
+}
+
+// switchStream hands the index of the stream to the player goroutine without
+// waiting for it, which may be crossfading. Only the latest stream asked for
+// is kept, the one pending is replaced.
+func (p *Player) switchStream(index int) {
+	for {
+		select {
+		case p.play <- index:
+			return
+		default:
+		}
+
+		select {
+		case <-p.play:
+		default:
+		}
+	}
+}
+
+func (p *Player) runDone() chan struct{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.done
 }
 
+// doPlay connects to the stream and starts playing it, when nothing else is
+// playing.
 func (p *Player) doPlay(stream string) error {
-	response, err := http.Get(stream)
+	conn, err := p.prepare(stream)
 	if err != nil {
 		return err
 	}
 
-	decoder, err := minimp3.NewDecoder(response.Body)
+	return p.start(conn, 0)
+}
+
+// prepare connects to the stream and starts decoding and buffering it, to be
+// started afterwards.
+func (p *Player) prepare(stream string) (*connection, error) {
+	conn, err := p.open(stream, 0, false)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	if isStarted := <-decoder.Started(); !isStarted {
-		return errors.New("cannot start decoding")
+	conn.stream = stream
+
+	var source io.Reader = newResampler(conn.decoder, contextSampleRate, contextNumChannels)
+	if p.normalization.Enabled {
+		p.mu.Lock()
+		gain := p.streamGains[stream]
+		p.mu.Unlock()
+
+		conn.normalizer = newNormalizer(source, p.normalization, gain, contextSampleRate, contextNumChannels)
+		source = conn.normalizer
 	}
 
-	// Check for more details https://github.com/hajimehoshi/oto/issues/149
-	if p.context == nil {
-		context, ready, err := oto.NewContext(contextSampleRate, contextNumChannels, 2)
-		if err != nil {
-			return err
-		}
+	p.mu.Lock()
+	filters, err := p.streamChain(stream)
+	p.mu.Unlock()
+
+	if err != nil {
+		log.Printf("Cannot build the DSP chain: %s\n", err)
+	}
 
-		go func() {
-			ticker := time.NewTicker(time.Second)
-			defer ticker.Stop()
+	conn.dsp = newDSPReader(conn.reader(source, p.watchdogPolicy, p.bufferPolicy), filters, contextNumChannels)
+	conn.meter = newMeter(conn.dsp, contextSampleRate, contextNumChannels)
 
-			for range ticker.C {
-				err := context.Err()
-				if err != nil {
-					p.errorHandler(err)
-				}
+	return conn, nil
+}
+
+// prepareSwitch prepares the stream in the background while the current one
+// keeps playing, and sends it once it is buffered. The stream is discarded if
+// the switch is canceled first.
+func (p *Player) prepareSwitch(index int, stream string) (chan<- struct{}, <-chan preparedStream) {
+	cancel := make(chan struct{})
+	prepared := make(chan preparedStream)
+
+	go func() {
+		conn, err := p.prepare(stream)
+		if err == nil {
+			select {
+			case <-conn.ready():
+			case <-time.After(crossfadeReadyTimeout):
+			case <-cancel:
 			}
-		}()
+		}
 
-		<-ready
+		select {
+		case prepared <- preparedStream{index: index, stream: stream, conn: conn, err: err}:
+		case <-cancel:
+			if conn != nil {
+				p.discard(conn)
+			}
+		}
+	}()
 
-		p.context = context
-	} else {
-		err = p.context.Resume()
+	return cancel, prepared
+}
+
+// start plays the prepared stream. If another stream is playing, it is
+// released after crossfading over the fade duration.
+func (p *Player) start(conn *connection, fade time.Duration) error {
+	p.mu.Lock()
+	prev, prevPlayer := p.conn, p.player
+	p.mu.Unlock()
+
+	if prev == nil {
+		err := p.sink.Resume()
 		if err != nil {
+			p.discard(conn)
+
 			return err
 		}
+
+		p.watchSink.Do(func() {
+			go func() {
+				ticker := time.NewTicker(time.Second)
+				defer ticker.Stop()
+
+				for range ticker.C {
+					err := p.sink.Err()
+					if err != nil {
+						p.errorHandler(err)
+					}
+				}
+			}()
+		})
 	}
 
-	p.stream = response.Body
-	p.decoder = decoder
-	p.player = p.context.NewPlayer(decoder)
+	p.followRecording(conn)
+	p.attachReplay(conn)
+	p.relay.attach(conn)
+
+	player := p.sink.NewStream(conn.meter)
+
+	player.SetVolume(0)
 
 	log.Printf(
-		"Audio stream initialized (url: %s, bitrate: %d, samplerate: %d, channels: %d)\n",
-		stream,
-		p.decoder.Kbps,
-		p.decoder.SampleRate,
-		p.decoder.Channels,
+		"Audio stream initialized (url: %s, format: %s, bitrate: %d, samplerate: %d, channels: %d)\n",
+		conn.url,
+		conn.format,
+		conn.decoder.Bitrate(),
+		conn.decoder.SampleRate(),
+		conn.decoder.Channels(),
 	)
 
-	p.player.SetVolume(p.volume)
-	p.player.Play()
+	player.Play()
+
+	if prev != nil {
+		p.fade(prevPlayer, player, fade)
+	}
+
+	p.mu.Lock()
+	p.conn = conn
+	p.player = player
+	p.crossfading = false
+	if prev == nil {
+		p.level = 0
+	}
+	p.mu.Unlock()
+
+	if prev != nil {
+		err := p.release(prev, prevPlayer)
+		if err != nil {
+			log.Printf("Cannot free the previous stream: %s\n", err)
+		}
+	} else {
+		go p.fadeIn(conn)
+	}
+
+	conn.OnMetadata(p.notifyMetadata)
+	p.notifyMetadata()
 
 	return nil
 }
 
-func (p *Player) run(index int) error {
-	err := p.doPlay(p.exactStream(index))
+// discard releases a stream prepared but never played.
+func (p *Player) discard(conn *connection) {
+	conn.watchdog.Close()
+	if conn.buffer != nil {
+		conn.buffer.Close()
+	}
+
+	err := conn.close()
+	if err != nil {
+		log.Printf("Cannot free the stream: %s\n", err)
+	}
+}
+
+// fadeIn raises the level from silence once the stream is buffered.
+func (p *Player) fadeIn(conn *connection) {
+	select {
+	case <-conn.ready():
+	case <-time.After(crossfadeReadyTimeout):
+	}
+
+	p.mu.Lock()
+	current := p.conn == conn
+	p.mu.Unlock()
+
+	if current {
+		p.rampLevel(p.Volume(), p.powerFade)
+	}
+}
+
+// fade crossfades to the new stream with equal-power gains, following volume
+// changes made during the fade.
+func (p *Player) fade(from, to SinkStream, duration time.Duration) {
+	p.mu.Lock()
+	p.crossfading = true
+	p.mu.Unlock()
+
+	start := time.Now()
+
+	for {
+		x := 1.0
+		if duration > 0 {
+			x = math.Min(float64(time.Since(start))/float64(duration), 1)
+		}
+
+		p.mu.Lock()
+		volume := p.level
+		p.mu.Unlock()
+
+		from.SetVolume(volume * math.Cos(x*math.Pi/2))
+		to.SetVolume(volume * math.Sin(x*math.Pi/2))
+
+		if x >= 1 {
+			return
+		}
+
+		time.Sleep(crossfadeStep)
+	}
+}
+
+func (p *Player) notifyMetadata() {
+	if p.metadataHandler == nil {
+		return
+	}
+
+	metadata := p.Metadata()
+	if metadata.Title != "" {
+		log.Printf("Now playing: %s\n", metadata.Title)
+	}
+
+	p.metadataHandler(metadata)
+}
+
+// preparedStream is a stream connected to in the background, to switch to.
+type preparedStream struct {
+	index  int
+	stream string
+	conn   *connection
+	err    error
+}
+
+// run plays the stream until stopped. Connection failures and streams ending
+// unexpectedly are retried according to the reconnect policy, the error is
+// only returned once the policy is exhausted. A switch is prepared in the
+// background while the current stream keeps playing, which goes on if the
+// next one cannot be connected to.
+func (p *Player) run(stream string) error {
+	var (
+		index    = p.streamNum() - 1
+		attempt  int
+		retry    <-chan time.Time
+		ended    <-chan error
+		alarms   <-chan WatchdogEvent
+		cancel   chan<- struct{}
+		prepared <-chan preparedStream
+	)
+
+	cancelSwitch := func() {
+		if cancel != nil {
+			close(cancel)
+			cancel, prepared = nil, nil
+		}
+	}
+
+	played := func() {
+		attempt = 0
+		ended = p.conn.ended
+		alarms = p.conn.watchdog.alarms
+		p.setState(StateEvent{State: StatePlaying, Stream: stream})
+	}
+
+	connect := func(err error) error {
+		if err == nil {
+			err = p.doPlay(stream)
+			if err == nil {
+				played()
+
+				return nil
+			}
+		}
+
+		attempt++
+		if p.reconnectPolicy.exhausted(attempt) {
+			err = fmt.Errorf("%s: giving up after %d attempts: %w", stream, attempt-1, err)
+			p.setState(StateEvent{State: StateFailed, Stream: stream, Attempt: attempt - 1, Err: err})
+
+			return err
+		}
+
+		delay := p.reconnectPolicy.delay(attempt)
+		log.Printf("Reconnecting in %s (attempt: %d): %s\n", delay.Round(time.Millisecond), attempt, err)
+		p.setState(StateEvent{State: StateReconnecting, Stream: stream, Attempt: attempt, Delay: delay, Err: err})
+
+		retry = time.After(delay)
+
+		return nil
+	}
+
+	err := connect(nil)
 	if err != nil {
 		return err
 	}
 
 	for {
 		select {
-		case url := <-p.play:
-			err := p.free()
+		case <-retry:
+			retry = nil
+
+			err = connect(nil)
 			if err != nil {
 				return err
 			}
 
-			err = p.doPlay(url)
+		case err = <-ended:
+			ended, alarms = nil, nil
+
+			if e := p.free(); e != nil {
+				log.Printf("Cannot free the stream: %s\n", e)
+			}
+
+			// The stream being switched to takes over once connected.
+			if prepared != nil {
+				continue
+			}
+
+			if err == nil || err == io.EOF {
+				err = errors.New("stream ended")
+			}
+
+			err = connect(err)
+			if err != nil {
+				return err
+			}
+
+		case event := <-alarms:
+			event.Stream = stream
+			event.Action = p.watchdogPolicy.action(event.Alarm)
+
+			log.Printf("Stream %s for %s (action: %s)\n", event.Alarm, event.Duration.Round(time.Second), event.Action)
+
+			if p.watchdogHandler != nil {
+				p.watchdogHandler(event)
+			}
+
+			switch event.Action {
+			case WatchdogReconnect:
+				ended, alarms = nil, nil
+
+				if e := p.free(); e != nil {
+					log.Printf("Cannot free the stream: %s\n", e)
+				}
+
+				err = ErrStreamStalled
+				if event.Alarm == AlarmSilent {
+					err = ErrStreamSilent
+				}
+
+				err = connect(err)
+				if err != nil {
+					return err
+				}
+
+			case WatchdogSkip:
+				err = p.free()
+				if err != nil {
+					return err
+				}
+
+				index, stream = p.nextStream()
+				attempt = 0
+				ended, alarms = nil, nil
+
+				p.setStream(index)
+
+				p.setState(StateEvent{State: StateConnecting, Stream: stream})
+
+				err = connect(nil)
+				if err != nil {
+					return err
+				}
+			}
+
+		case next := <-p.play:
+			cancelSwitch()
+			retry = nil
+			// The stream playing is not skipped or reconnected while switching.
+			alarms = nil
+
+			p.setState(StateEvent{State: StateConnecting, Stream: p.streams[next]})
+
+			cancel, prepared = p.prepareSwitch(next, p.streams[next])
+
+		case next := <-prepared:
+			cancel, prepared = nil, nil
+
+			p.mu.Lock()
+			playing := p.conn
+			p.mu.Unlock()
+
+			if next.err != nil && playing != nil {
+				log.Printf("Cannot switch streams, going on with %s: %s\n", stream, next.err)
+
+				p.restoreTarget(next.index)
+				alarms = playing.watchdog.alarms
+				p.setState(StateEvent{State: StatePlaying, Stream: stream})
+
+				continue
+			}
+
+			// Without a stream playing, the next one is retried instead.
+			index, stream, attempt = next.index, next.stream, 0
+
+			err = next.err
+			if err == nil {
+				err = p.start(next.conn, p.crossfade)
+			}
+
+			p.setStream(index)
+
+			if err == nil {
+				played()
+
+				continue
+			}
+
+			err = connect(err)
 			if err != nil {
 				return err
 			}
 
 		case <-p.stop:
-			return nil
+			cancelSwitch()
+
+			p.mu.Lock()
+			p.target = p.index
+			p.mu.Unlock()
+
+			err = p.free()
+			p.setState(StateEvent{State: StateStopped, Stream: stream})
+
+			return err
 		}
 	}
 }
 
+// setStream makes the stream switched to the current one and reports it.
+func (p *Player) setStream(index int) {
+	p.mu.Lock()
+	p.index = index
+	p.mu.Unlock()
+
+	if p.streamHandler != nil {
+		p.streamHandler(index + 1)
+	}
+}
+
+// restoreTarget goes back to the current stream after a failed switch, unless another one has been asked for since.
+func (p *Player) restoreTarget(failed int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.target == failed {
+		p.target = p.index
+	}
+}
+
+func (p *Player) setState(event StateEvent) {
+	p.mu.Lock()
+	p.state = event.State
+	p.mu.Unlock()
+
+	if p.stateHandler != nil {
+		p.stateHandler(event)
+	}
+}
+
 func (p *Player) free() error {
-	err := p.player.Close()
-	if err != nil {
-		return err
+	p.mu.Lock()
+	conn, player := p.conn, p.player
+	p.conn, p.player = nil, nil
+	p.mu.Unlock()
+
+	if conn == nil {
+		return nil
 	}
 
-	p.decoder.Close()
+	err := p.release(conn, player)
 
-	err = p.stream.Close()
-	if err != nil {
-		return err
+	if e := p.sink.Suspend(); e != nil && err == nil {
+		err = e
 	}
 
-	err = p.context.Suspend()
-	if err != nil {
-		return err
+	return err
+}
+
+func (p *Player) release(conn *connection, player SinkStream) error {
+	p.mu.Lock()
+	p.underruns += conn.BufferStats().Underruns
+	p.mu.Unlock()
+
+	p.learnGain(conn)
+
+	conn.watchdog.Close()
+	if conn.buffer != nil {
+		conn.buffer.Close()
 	}
 
-	p.stream = nil
-	p.decoder = nil
-	p.player = nil
+	// Closing the body first unblocks the sink stream waiting for stream data.
+	err := conn.body.Close()
 
-	return nil
+	if e := player.Close(); e != nil && err == nil {
+		err = e
+	}
+
+	if e := conn.decoder.Close(); e != nil && err == nil {
+		err = e
+	}
+
+	return err
+}
+
+// learnGain keeps the normalization gain of the stream, once it has been
+// measured long enough, to start from it the next time.
+func (p *Player) learnGain(conn *connection) {
+	if conn.normalizer == nil {
+		return
+	}
+
+	gain, measured := conn.normalizer.Gain()
+	if measured < normalizationLearnTime {
+		return
+	}
+
+	p.mu.Lock()
+	p.streamGains[conn.stream] = gain
+	p.mu.Unlock()
+
+	if p.gainHandler != nil {
+		p.gainHandler(conn.stream, gain)
+	}
 }
 
 func (p *Player) currentStream() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
 	return p.streams[p.index]
 }
 
+// streamNum is the number of the current stream, starting from 1.
+func (p *Player) streamNum() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.index + 1
+}
+
 func (p *Player) exactStream(index int) string {
-	p.index = index
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.index, p.target = index, index
 
 	return p.streams[p.index]
 }
 
-func (p *Player) prevStream() string {
-	p.index--
-	if p.index < 0 {
-		p.index = len(p.streams) - 1
-	}
+// moveTarget moves the stream asked for by the step, from the one pending if
+// a switch is.
+func (p *Player) moveTarget(step int) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
 
-	return p.streams[p.index]
+	p.target = (p.target + step + len(p.streams)) % len(p.streams)
+
+	return p.target
 }
 
-func (p *Player) nextStream() string {
-	p.index++
-	if p.index > len(p.streams)-1 {
-		p.index = 0
-	}
+// nextStream returns the stream after the current one, for the watchdog to
+// skip to.
+func (p *Player) nextStream() (int, string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
 
-	return p.streams[p.index]
+	index := (p.index + 1) % len(p.streams)
+	p.target = index
+
+	return index, p.streams[index]
 }
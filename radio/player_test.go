@@ -0,0 +1,198 @@
+package radio
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func waitForState(t *testing.T, p *Player, state State) {
+	t.Helper()
+
+	for deadline := time.Now().Add(5 * time.Second); p.State() != state; {
+		if time.Now().After(deadline) {
+			t.Fatalf("got state %s, want %s", p.State(), state)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// waitForSwitch waits for the stream asked for to be the current one again.
+func waitForSwitch(t *testing.T, p *Player) {
+	t.Helper()
+
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		p.mu.Lock()
+		switched := p.target == p.index
+		p.mu.Unlock()
+
+		if switched {
+			return
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatal("still switching")
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestFailedSwitchKeepsPlaying(t *testing.T) {
+	server, _ := newPCMTestServer(t)
+
+	missing := httptest.NewServer(http.NotFoundHandler())
+	t.Cleanup(missing.Close)
+
+	p := newTestPlayer(t, server.URL, missing.URL)
+	t.Cleanup(p.Stop)
+
+	changes := make(chan int, 1)
+	p.OnStreamChange(func(num int) {
+		changes <- num
+	})
+
+	if num := p.Next(); num != 2 {
+		t.Fatalf("switching to stream %d, want 2", num)
+	}
+
+	waitForSwitch(t, p)
+	waitForState(t, p, StatePlaying)
+
+	select {
+	case num := <-changes:
+		t.Fatalf("the failed switch is reported as stream %d", num)
+	default:
+	}
+
+	if metadata := p.Metadata(); metadata.Stream != 1 || metadata.URL != server.URL {
+		t.Fatalf("playing stream %d (%s), want the first one", metadata.Stream, metadata.URL)
+	}
+
+	if num := p.Next(); num != 2 {
+		t.Fatalf("switching to stream %d after the failed switch, want 2", num)
+	}
+}
+
+func TestSwitchCommitsOnceConnected(t *testing.T) {
+	first, _ := newPCMTestServer(t)
+	second, _ := newPCMTestServer(t)
+
+	p := newTestPlayer(t, first.URL, second.URL)
+	t.Cleanup(p.Stop)
+
+	changes := make(chan int, 1)
+	p.OnStreamChange(func(num int) {
+		changes <- num
+	})
+
+	if num := p.Next(); num != 2 {
+		t.Fatalf("switching to stream %d, want 2", num)
+	}
+
+	select {
+	case num := <-changes:
+		if num != 2 {
+			t.Fatalf("switched to stream %d, want 2", num)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the switch is not reported")
+	}
+
+	if metadata := p.Metadata(); metadata.Stream != 2 || metadata.URL != second.URL {
+		t.Fatalf("playing stream %d (%s), want the second one", metadata.Stream, metadata.URL)
+	}
+}
+
+func TestStopWhileSwitching(t *testing.T) {
+	server, _ := newPCMTestServer(t)
+
+	// The next station does not answer until the end of the test.
+	release := make(chan struct{})
+	stalled := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	t.Cleanup(stalled.Close)
+	t.Cleanup(func() {
+		close(release)
+	})
+
+	p := newTestPlayer(t, server.URL, stalled.URL)
+
+	p.Next()
+	waitForState(t, p, StateConnecting)
+
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- p.Close()
+	}()
+
+	select {
+	case err := <-stopped:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("stop waits for the switch")
+	}
+
+	if p.IsPlaying() {
+		t.Fatalf("still playing: %s", p.State())
+	}
+}
+
+func TestCloseFadesOutWhileSwitching(t *testing.T) {
+	server, _ := newPCMTestServer(t)
+
+	release := make(chan struct{})
+	stalled := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	t.Cleanup(stalled.Close)
+	t.Cleanup(func() {
+		close(release)
+	})
+
+	p := newTestPlayer(t, server.URL, stalled.URL)
+
+	// The fade in once buffered would take over from the fade out.
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		p.mu.Lock()
+		faded := p.level == p.volume
+		p.mu.Unlock()
+
+		if faded {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatal("not faded in")
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	p.SetPowerFade(300 * time.Millisecond)
+
+	p.Next()
+	waitForState(t, p, StateConnecting)
+
+	start := time.Now()
+
+	err := p.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if elapsed := time.Since(start); elapsed < 300*time.Millisecond {
+		t.Fatalf("closed in %s without fading out", elapsed)
+	}
+}
@@ -0,0 +1,190 @@
+package radio
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	playlistSniffSize = 512
+	playlistMaxSize   = 1 << 20
+	playlistMaxDepth  = 3
+)
+
+var ErrEmptyPlaylist = errors.New("playlist has no entries")
+
+type playlistFormat struct {
+	name         string
+	contentTypes []string
+	extensions   []string
+	sniff        func(header []byte) bool
+	parse        func(body []byte) ([]string, error)
+}
+
+var playlistFormats = []playlistFormat{
+	{
+		name:         "m3u",
+		contentTypes: []string{"audio/x-mpegurl", "audio/mpegurl", "application/x-mpegurl", "application/vnd.apple.mpegurl", "audio/m3u", "audio/x-m3u"},
+		extensions:   []string{".m3u", ".m3u8"},
+		sniff:        func(header []byte) bool { return bytes.HasPrefix(header, []byte("#EXTM3U")) },
+		parse:        parseM3U,
+	},
+	{
+		name:         "pls",
+		contentTypes: []string{"audio/x-scpls", "audio/scpls", "application/pls+xml"},
+		extensions:   []string{".pls"},
+		sniff: func(header []byte) bool {
+			return bytes.HasPrefix(bytes.ToLower(header), []byte("[playlist]"))
+		},
+		parse: parsePLS,
+	},
+	{
+		name:         "xspf",
+		contentTypes: []string{"application/xspf+xml"},
+		extensions:   []string{".xspf"},
+		sniff: func(header []byte) bool {
+			return bytes.HasPrefix(header, []byte("<")) && bytes.Contains(header, []byte("http://xspf.org/ns/0/"))
+		},
+		parse: parseXSPF,
+	},
+}
+
+// detectPlaylist recognizes a playlist by the content type, the first bytes
+// of the body or, for servers sending a generic content type, by the URL extension.
+func detectPlaylist(location *url.URL, contentType string, reader *bufio.Reader) (playlistFormat, bool) {
+	header, _ := reader.Peek(playlistSniffSize)
+	header = bytes.TrimLeft(header, "\xEF\xBB\xBF \t\r\n")
+	contentType = mediaType(contentType)
+
+	for _, format := range playlistFormats {
+		for _, t := range format.contentTypes {
+			if t == contentType {
+				return format, true
+			}
+		}
+	}
+
+	for _, format := range playlistFormats {
+		if format.sniff(header) {
+			return format, true
+		}
+	}
+
+	if strings.HasPrefix(contentType, "audio/") || strings.HasPrefix(contentType, "application/ogg") {
+		return playlistFormat{}, false
+	}
+
+	ext := strings.ToLower(path.Ext(location.Path))
+	for _, format := range playlistFormats {
+		for _, e := range format.extensions {
+			if e == ext {
+				return format, true
+			}
+		}
+	}
+
+	return playlistFormat{}, false
+}
+
+func parsePlaylist(format playlistFormat, location *url.URL, body []byte) ([]string, error) {
+	entries, err := format.parse(body)
+	if err != nil {
+		return nil, fmt.Errorf("%s playlist: %w", format.name, err)
+	}
+
+	var streams []string
+	for _, entry := range entries {
+		ref, err := url.Parse(strings.TrimSpace(entry))
+		if err != nil || entry == "" {
+			continue
+		}
+
+		stream := location.ResolveReference(ref)
+		if stream.Scheme != "http" && stream.Scheme != "https" {
+			continue
+		}
+
+		streams = append(streams, stream.String())
+	}
+
+	if len(streams) == 0 {
+		return nil, ErrEmptyPlaylist
+	}
+
+	return streams, nil
+}
+
+func parseM3U(body []byte) ([]string, error) {
+	var entries []string
+
+	for _, line := range strings.Split(string(body), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		entries = append(entries, line)
+	}
+
+	return entries, nil
+}
+
+func parsePLS(body []byte) ([]string, error) {
+	files := make(map[int]string)
+
+	for _, line := range strings.Split(string(body), "\n") {
+		parts := strings.SplitN(strings.TrimSpace(line), "=", 2)
+		if len(parts) != 2 || !strings.HasPrefix(strings.ToLower(parts[0]), "file") {
+			continue
+		}
+
+		num, err := strconv.Atoi(parts[0][len("file"):])
+		if err != nil {
+			continue
+		}
+
+		files[num] = parts[1]
+	}
+
+	nums := make([]int, 0, len(files))
+	for num := range files {
+		nums = append(nums, num)
+	}
+
+	sort.Ints(nums)
+
+	entries := make([]string, 0, len(nums))
+	for _, num := range nums {
+		entries = append(entries, files[num])
+	}
+
+	return entries, nil
+}
+
+func parseXSPF(body []byte) ([]string, error) {
+	var playlist struct {
+		Tracks []struct {
+			Locations []string `xml:"location"`
+		} `xml:"trackList>track"`
+	}
+
+	err := xml.Unmarshal(body, &playlist)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []string
+	for _, track := range playlist.Tracks {
+		entries = append(entries, track.Locations...)
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,66 @@
+package radio
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+type State string
+
+const (
+	StateStopped      State = "stopped"
+	StateConnecting   State = "connecting"
+	StatePlaying      State = "playing"
+	StateReconnecting State = "reconnecting"
+	StateFailed       State = "failed"
+)
+
+type StateEvent struct {
+	State   State
+	Stream  string
+	Attempt int
+	Delay   time.Duration
+	Err     error
+}
+
+type StateHandler func(event StateEvent)
+
+// ReconnectPolicy controls how the player reconnects to the same station
+// after a connection failure. MaxAttempts <= 0 means retrying forever.
+type ReconnectPolicy struct {
+	MaxAttempts  int
+	InitialDelay time.Duration
+	MaxDelay     time.Duration
+	Multiplier   float64
+	Jitter       float64
+}
+
+func DefaultReconnectPolicy() ReconnectPolicy {
+	return ReconnectPolicy{
+		MaxAttempts:  10,
+		InitialDelay: time.Second,
+		MaxDelay:     30 * time.Second,
+		Multiplier:   2,
+		Jitter:       0.2,
+	}
+}
+
+func (p ReconnectPolicy) exhausted(attempt int) bool {
+	return p.MaxAttempts > 0 && attempt > p.MaxAttempts
+}
+
+// delay returns the exponential backoff for the attempt (starting from 1),
+// randomized by +/- Jitter of its value.
+func (p ReconnectPolicy) delay(attempt int) time.Duration {
+	delay := float64(p.InitialDelay) * math.Pow(p.Multiplier, float64(attempt-1))
+	if p.MaxDelay > 0 && delay > float64(p.MaxDelay) {
+		delay = float64(p.MaxDelay)
+	}
+
+	if p.Jitter > 0 {
+		delay += delay * p.Jitter * (2*rand.Float64() - 1)
+	}
+
+	return time.Duration(delay)
+}
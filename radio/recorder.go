@@ -0,0 +1,464 @@
+package radio
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+const (
+	fileTimeLayout         = "2006-01-02_15-04-05"
+	fileTitleMaxSize       = 80
+	recordingFilePerm      = 0644
+	recordingDirectoryPerm = 0755
+)
+
+var (
+	ErrNotPlaying       = errors.New("not playing")
+	ErrRecording        = errors.New("already recording")
+	ErrRecordingQuota   = errors.New("recording quota exceeded")
+	ErrRecordingStopped = errors.New("recording stopped")
+)
+
+// RecordingPolicy controls the recordings. The raw stream data is written to
+// timestamped files in Dir, stopping after MaxDuration or once the files in
+// Dir take Quota bytes (zero for no limit). SplitTracks starts a new file on
+// each ICY title change. With Follow the recording follows the station
+// switches, otherwise it stays on the station it started with, using its own
+// connection once the player is not playing it anymore.
+type RecordingPolicy struct {
+	Dir         string
+	MaxDuration time.Duration
+	Quota       int64
+	SplitTracks bool
+	Follow      bool
+}
+
+// RecordingStatus describes the current recording, or the last one with the
+// error that stopped it.
+type RecordingStatus struct {
+	Recording bool
+	Stream    string
+	File      string
+	Started   time.Time
+	Size      int64
+	Err       error
+}
+
+type recorder struct {
+	player  *Player
+	policy  RecordingPolicy
+	status  RecordingStatus
+	format  string
+	title   string
+	file    *os.File
+	used    int64
+	conn    *connection
+	owned   bool
+	cancel  func()
+	timer   *time.Timer
+	stopped chan struct{}
+	mu      sync.Mutex
+}
+
+// StartRecording records the current stream until StopRecording is called
+// or a limit of the policy is reached.
+func (p *Player) StartRecording(policy RecordingPolicy) error {
+	// The directory is walked before locking the player, which it would stall.
+	used, err := recordingUsage(policy)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.recorder != nil && p.recorder.Status().Recording {
+		return ErrRecording
+	}
+
+	if p.conn == nil {
+		return ErrNotPlaying
+	}
+
+	r := newRecorder(p, policy, p.conn.stream, used)
+	p.recorder = r
+
+	if policy.Follow {
+		r.attach(p.conn, false)
+	} else {
+		go r.run()
+	}
+
+	return nil
+}
+
+func (p *Player) StopRecording() {
+	p.mu.Lock()
+	r := p.recorder
+	p.mu.Unlock()
+
+	if r != nil {
+		r.stop(ErrRecordingStopped)
+	}
+}
+
+func (p *Player) Recording() RecordingStatus {
+	p.mu.Lock()
+	r := p.recorder
+	p.mu.Unlock()
+
+	if r == nil {
+		return RecordingStatus{}
+	}
+
+	return r.Status()
+}
+
+// followRecording moves a recording following the station switches to the
+// new connection.
+func (p *Player) followRecording(conn *connection) {
+	p.mu.Lock()
+	r := p.recorder
+	p.mu.Unlock()
+
+	if r != nil && r.policy.Follow {
+		r.attach(conn, false)
+	}
+}
+
+// streamConn returns the connection of the player if it is playing the
+// stream.
+func (p *Player) streamConn(stream string) *connection {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.conn != nil && p.conn.stream == stream {
+		return p.conn
+	}
+
+	return nil
+}
+
+// stopFollowing stops a recording following the player, the ones staying on
+// their station go on.
+func (p *Player) stopFollowing() {
+	p.mu.Lock()
+	r := p.recorder
+	p.mu.Unlock()
+
+	if r != nil && r.policy.Follow {
+		r.stop(ErrRecordingStopped)
+	}
+}
+
+// recordingUsage returns the size of the recording directory, which it
+// creates, failing once it is over the quota.
+func recordingUsage(policy RecordingPolicy) (int64, error) {
+	err := os.MkdirAll(policy.Dir, recordingDirectoryPerm)
+	if err != nil {
+		return 0, err
+	}
+
+	used, err := directorySize(policy.Dir)
+	if err != nil {
+		return 0, err
+	}
+
+	if policy.Quota > 0 && used >= policy.Quota {
+		return 0, ErrRecordingQuota
+	}
+
+	return used, nil
+}
+
+func newRecorder(player *Player, policy RecordingPolicy, stream string, used int64) *recorder {
+	r := &recorder{
+		player:  player,
+		policy:  policy,
+		status:  RecordingStatus{Recording: true, Stream: stream, Started: time.Now()},
+		used:    used,
+		stopped: make(chan struct{}),
+	}
+
+	if policy.MaxDuration > 0 {
+		r.timer = time.AfterFunc(policy.MaxDuration, func() {
+			r.stop(nil)
+		})
+	}
+
+	return r
+}
+
+func (r *recorder) Status() RecordingStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.status
+}
+
+// attach takes the data of the connection from now on, owned if it is not
+// the connection of the player. A new station starts a new file.
+func (r *recorder) attach(conn *connection, owned bool) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.status.Recording {
+		return false
+	}
+
+	if r.cancel != nil {
+		r.cancel()
+	}
+
+	if conn.stream != r.status.Stream || conn.format != r.format {
+		r.closeFile()
+	}
+
+	r.conn, r.owned, r.format, r.status.Stream = conn, owned, conn.format, conn.stream
+	r.cancel = conn.tap.Subscribe(func(data, headers []byte) {
+		r.write(conn, data, headers)
+	})
+
+	return true
+}
+
+// run records the station from the connection of the player while it is
+// playing it, and from a connection of its own once the player is done with
+// it, read as received and reconnecting like the player.
+func (r *recorder) run() {
+	stream := r.Status().Stream
+	policy := r.player.reconnectPolicy
+	attempt := 0
+
+	for {
+		if conn := r.player.streamConn(stream); conn != nil {
+			if !r.attach(conn, false) {
+				return
+			}
+
+			select {
+			case <-r.stopped:
+				return
+			case <-conn.tap.Done():
+			}
+
+			continue
+		}
+
+		conn, err := r.player.open(stream, 0, true)
+		if err == nil {
+			attempt = 0
+			conn.stream = stream
+			if !r.attach(conn, true) {
+				_ = conn.close()
+
+				return
+			}
+
+			_, err = io.Copy(io.Discard, conn.body)
+			_ = conn.close()
+
+			if err == nil {
+				err = io.EOF
+			}
+		}
+
+		select {
+		case <-r.stopped:
+			return
+		default:
+		}
+
+		attempt++
+		if policy.exhausted(attempt) {
+			r.stop(err)
+
+			return
+		}
+
+		log.Printf("Recording connection lost: %s\n", err)
+
+		select {
+		case <-r.stopped:
+			return
+		case <-time.After(policy.delay(attempt)):
+		}
+	}
+}
+
+func (r *recorder) write(conn *connection, data, headers []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.status.Recording || conn != r.conn {
+		return
+	}
+
+	title := conn.Metadata().Title
+	if r.policy.SplitTracks && r.file != nil && title != r.title {
+		r.closeFile()
+	}
+
+	r.title = title
+
+	if r.file == nil {
+		err := r.openFile(headers)
+		if err != nil {
+			r.finish(err)
+
+			return
+		}
+	}
+
+	err := r.writeFile(data)
+	if err != nil {
+		r.finish(err)
+	}
+}
+
+// openFile must be called with the mutex held. Ogg streams cannot be decoded
+// without their headers, so the file starts with the current ones.
+func (r *recorder) openFile(headers []byte) error {
+	name := fileName(time.Now(), r.title)
+	ext := "." + formatExtension(r.format)
+
+	file, err := os.OpenFile(filepath.Join(r.policy.Dir, name+ext), os.O_WRONLY|os.O_CREATE|os.O_EXCL, recordingFilePerm)
+	for i := 2; os.IsExist(err); i++ {
+		file, err = os.OpenFile(filepath.Join(r.policy.Dir, fmt.Sprintf("%s_%d%s", name, i, ext)), os.O_WRONLY|os.O_CREATE|os.O_EXCL, recordingFilePerm)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	r.file = file
+	r.status.File = file.Name()
+
+	if len(headers) > 0 {
+		return r.writeFile(headers)
+	}
+
+	return nil
+}
+
+// writeFile must be called with the mutex held.
+func (r *recorder) writeFile(data []byte) error {
+	if r.policy.Quota > 0 && r.used+int64(len(data)) > r.policy.Quota {
+		return ErrRecordingQuota
+	}
+
+	n, err := r.file.Write(data)
+	r.used += int64(n)
+	r.status.Size += int64(n)
+
+	return err
+}
+
+// closeFile must be called with the mutex held.
+func (r *recorder) closeFile() {
+	if r.file == nil {
+		return
+	}
+
+	err := r.file.Close()
+	if err != nil {
+		log.Printf("Cannot close the recording: %s\n", err)
+	}
+
+	r.file = nil
+}
+
+func (r *recorder) stop(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.finish(err)
+}
+
+// finish must be called with the mutex held. The connection of its own is
+// closed by its body, so run stops reading it.
+func (r *recorder) finish(err error) {
+	if !r.status.Recording {
+		return
+	}
+
+	if r.cancel != nil {
+		r.cancel()
+	}
+
+	if r.timer != nil {
+		r.timer.Stop()
+	}
+
+	if r.owned {
+		_ = r.conn.body.Close()
+	}
+
+	r.closeFile()
+	close(r.stopped)
+
+	if err == ErrRecordingStopped {
+		err = nil
+	}
+
+	r.status.Recording, r.status.Err = false, err
+
+	if err != nil {
+		log.Printf("Recording stopped: %s\n", err)
+	}
+}
+
+func directorySize(dir string) (int64, error) {
+	var size int64
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.Mode().IsRegular() {
+			size += info.Size()
+		}
+
+		return nil
+	})
+
+	return size, err
+}
+
+// fileName names a file after the time and the stream title.
+func fileName(at time.Time, title string) string {
+	name := at.Format(fileTimeLayout)
+
+	title = strings.Trim(strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '.' {
+			return r
+		}
+
+		return '_'
+	}, strings.TrimSpace(title)), "_.")
+
+	if runes := []rune(title); len(runes) > fileTitleMaxSize {
+		title = string(runes[:fileTitleMaxSize])
+	}
+
+	if title != "" {
+		name += "_" + title
+	}
+
+	return name
+}
+
+// formatExtension returns the file extension of the stream format, like mp3
+// for hls/mp3.
+func formatExtension(format string) string {
+	return format[strings.LastIndex(format, "/")+1:]
+}
@@ -0,0 +1,95 @@
+package radio
+
+import (
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRecordingGoesOnAfterPowerOff(t *testing.T) {
+	server, connections := newPCMTestServer(t)
+	p := newTestPlayer(t, server.URL)
+
+	err := p.StartRecording(RecordingPolicy{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(500 * time.Millisecond)
+
+	recorded := p.Recording().Size
+	if recorded == 0 {
+		t.Fatal("nothing recorded while playing")
+	}
+
+	if n := atomic.LoadInt32(connections); n != 1 {
+		t.Fatalf("got %d connections while playing, want the one of the player", n)
+	}
+
+	err = p.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(time.Second)
+
+	status := p.Recording()
+	if !status.Recording {
+		t.Fatalf("recording stopped with the player: %v", status.Err)
+	}
+
+	if status.Size <= recorded {
+		t.Fatal("nothing recorded after power off")
+	}
+
+	if n := atomic.LoadInt32(connections); n != 2 {
+		t.Fatalf("got %d connections after power off, want one more for the recording", n)
+	}
+
+	p.StopRecording()
+
+	status = p.Recording()
+	if status.Recording || status.Err != nil {
+		t.Fatalf("recording not stopped cleanly: %+v", status)
+	}
+
+	info, err := os.Stat(status.File)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Size() != status.Size {
+		t.Fatalf("file of %d bytes, recorded %d", info.Size(), status.Size)
+	}
+}
+
+func TestFollowingRecordingStopsWithPlayer(t *testing.T) {
+	server, connections := newPCMTestServer(t)
+	p := newTestPlayer(t, server.URL)
+
+	err := p.StartRecording(RecordingPolicy{Dir: t.TempDir(), Follow: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(300 * time.Millisecond)
+
+	err = p.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	status := p.Recording()
+	if status.Recording || status.Err != nil {
+		t.Fatalf("recording not stopped with the player: %+v", status)
+	}
+
+	if status.Size == 0 {
+		t.Fatal("nothing recorded")
+	}
+
+	if n := atomic.LoadInt32(connections); n != 1 {
+		t.Fatalf("got %d connections, want the one of the player", n)
+	}
+}
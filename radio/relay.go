@@ -0,0 +1,158 @@
+package radio
+
+import (
+	"sync"
+)
+
+// relayListenerQueueSize is how many chunks a listener may fall behind before
+// it is dropped, so a slow listener never holds the stream back.
+const relayListenerQueueSize = 256
+
+// RelayListener receives the raw data of the streams played, as received,
+// following the station switches. Chunks is closed when the listener falls
+// behind, the stream format changes or the playback stops.
+type RelayListener struct {
+	format string
+	chunks chan []byte
+	head   bool
+	closed bool
+	relay  *relay
+}
+
+// relay passes the data of the current connection to its listeners.
+type relay struct {
+	format    string
+	cancel    func()
+	listeners map[*RelayListener]struct{}
+	mu        sync.Mutex
+}
+
+func newRelay() *relay {
+	return &relay{listeners: make(map[*RelayListener]struct{})}
+}
+
+// Relay adds a listener of the stream playing.
+func (p *Player) Relay() (*RelayListener, error) {
+	return p.relay.listen()
+}
+
+// Format is the format of the stream relayed, like mp3 or hls/aac.
+func (l *RelayListener) Format() string {
+	return l.format
+}
+
+func (l *RelayListener) Chunks() <-chan []byte {
+	return l.chunks
+}
+
+func (l *RelayListener) Close() {
+	l.relay.mu.Lock()
+	defer l.relay.mu.Unlock()
+
+	l.relay.remove(l)
+}
+
+func (r *relay) listen() (*RelayListener, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.format == "" {
+		return nil, ErrNotPlaying
+	}
+
+	l := &RelayListener{
+		format: r.format,
+		chunks: make(chan []byte, relayListenerQueueSize),
+		head:   true,
+		relay:  r,
+	}
+
+	r.listeners[l] = struct{}{}
+
+	return l, nil
+}
+
+// attach relays the data of the new connection. The listeners cannot follow
+// a change of the format, they are closed so they reconnect. HLS streams
+// relay their segments demuxed, in the format of their audio.
+func (r *relay) attach(conn *connection) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.cancel != nil {
+		r.cancel()
+	}
+
+	for l := range r.listeners {
+		if formatExtension(l.format) != formatExtension(conn.format) {
+			r.remove(l)
+
+			continue
+		}
+
+		l.head = true
+	}
+
+	r.format = conn.format
+	r.cancel = conn.tap.Subscribe(r.write)
+}
+
+// detach closes the listeners once the playback stops.
+func (r *relay) detach() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.cancel != nil {
+		r.cancel()
+		r.cancel = nil
+	}
+
+	for l := range r.listeners {
+		r.remove(l)
+	}
+
+	r.format = ""
+}
+
+// write passes a copy of the data to each listener. Ogg streams cannot be
+// decoded without their headers, a listener joining or switching station
+// gets the current headers first.
+func (r *relay) write(data, headers []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for l := range r.listeners {
+		if l.head {
+			l.head = false
+			if len(headers) > 0 && !r.send(l, append([]byte(nil), headers...)) {
+				continue
+			}
+		}
+
+		r.send(l, append([]byte(nil), data...))
+	}
+}
+
+// send must be called with the mutex held. A listener too slow to take the
+// data is dropped.
+func (r *relay) send(l *RelayListener, data []byte) bool {
+	select {
+	case l.chunks <- data:
+		return true
+	default:
+		r.remove(l)
+
+		return false
+	}
+}
+
+// remove must be called with the mutex held.
+func (r *relay) remove(l *RelayListener) {
+	if l.closed {
+		return
+	}
+
+	l.closed = true
+	delete(r.listeners, l)
+	close(l.chunks)
+}
@@ -0,0 +1,235 @@
+package radio
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	ErrNoReplay        = errors.New("replay disabled")
+	ErrStreamChanged   = errors.New("stream changed")
+	ErrReplayNotEnough = errors.New("not enough audio received")
+)
+
+// ReplayPolicy controls the rolling buffer of the raw stream data kept for
+// the clips. A zero Duration disables it.
+type ReplayPolicy struct {
+	Duration time.Duration
+}
+
+func DefaultReplayPolicy() ReplayPolicy {
+	return ReplayPolicy{Duration: time.Minute}
+}
+
+// Clip is raw stream data as received, in the format of the stream.
+type Clip struct {
+	Stream string
+	Title  string
+	Format string
+	Data   []byte
+}
+
+// FileName names the clip after the time and the stream title, with the
+// extension of its format.
+func (c Clip) FileName(at time.Time) string {
+	return fileName(at, c.Title) + "." + formatExtension(c.Format)
+}
+
+// replayChunk is data received at a time, with the Ogg headers needed to
+// decode it.
+type replayChunk struct {
+	at      time.Time
+	data    []byte
+	headers []byte
+}
+
+// replayBuffer keeps the raw data received within the keep duration, with the
+// time it was received at. The station playing is kept along with the one it
+// is crossfading to, as it is heard until the crossfade ends.
+type replayBuffer struct {
+	keep    time.Duration
+	sources []*replaySource
+	holds   map[*time.Time]struct{}
+	mu      sync.Mutex
+}
+
+type replaySource struct {
+	conn   *connection
+	stream string
+	format string
+	chunks []replayChunk
+	cancel func()
+}
+
+func (p *Player) SetReplayPolicy(policy ReplayPolicy) {
+	p.replayPolicy = policy
+}
+
+// Clip returns the audio played between from and to, waiting until it is
+// played. The audio is received ahead of the output by the buffered level,
+// which includes how far the output is behind live after the pauses.
+func (p *Player) Clip(from, to time.Time) (Clip, error) {
+	p.mu.Lock()
+	replay, conn := p.replay, p.conn
+	p.mu.Unlock()
+
+	if replay == nil || p.replayPolicy.Duration <= 0 {
+		return Clip{}, ErrNoReplay
+	}
+
+	if conn == nil {
+		return Clip{}, ErrNotPlaying
+	}
+
+	if earliest := time.Now().Add(-p.replayPolicy.Duration); from.Before(earliest) {
+		from = earliest
+	}
+
+	lag := conn.BufferStats().Level
+
+	release := replay.hold(from.Add(-lag))
+	defer release()
+
+	if wait := time.Until(to.Add(-lag)); wait > 0 {
+		time.Sleep(wait)
+	}
+
+	clip, err := replay.clip(conn, from.Add(-lag), to.Add(-lag))
+	if err != nil {
+		return Clip{}, err
+	}
+
+	clip.Title = conn.Metadata().Title
+
+	return clip, nil
+}
+
+// attachReplay keeps the data of the new connection. The data of the station
+// playing is kept until the next switch, the other ones are dropped.
+func (p *Player) attachReplay(conn *connection) {
+	if p.replayPolicy.Duration <= 0 {
+		return
+	}
+
+	p.mu.Lock()
+	if p.replay == nil {
+		p.replay = &replayBuffer{holds: make(map[*time.Time]struct{})}
+	}
+	replay, current := p.replay, p.conn
+	p.mu.Unlock()
+
+	replay.attach(conn, current, p.replayPolicy.Duration+p.bufferPolicy.Size+p.bufferPolicy.TimeShift)
+}
+
+func (r *replayBuffer) attach(conn, current *connection, keep time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var (
+		next    *replaySource
+		sources []*replaySource
+	)
+
+	for _, source := range r.sources {
+		switch {
+		case next == nil && (source.conn == current || current == nil) &&
+			source.stream == conn.stream && source.format == conn.format:
+			// A reconnection to the same station goes on with its data.
+			source.cancel()
+			next = source
+		case current != nil && source.conn == current:
+			sources = append(sources, source)
+		default:
+			source.cancel()
+		}
+	}
+
+	if next == nil {
+		next = &replaySource{stream: conn.stream, format: conn.format}
+	}
+
+	next.conn = conn
+	next.cancel = conn.tap.Subscribe(func(data, headers []byte) {
+		r.write(next, data, headers)
+	})
+
+	r.keep, r.sources = keep, append(sources, next)
+}
+
+func (r *replayBuffer) write(source *replaySource, data, headers []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+
+	source.chunks = append(source.chunks, replayChunk{at: now, data: append([]byte(nil), data...), headers: headers})
+
+	oldest := now.Add(-r.keep)
+	for from := range r.holds {
+		if from.Before(oldest) {
+			oldest = *from
+		}
+	}
+
+	i := 0
+	for i < len(source.chunks) && source.chunks[i].at.Before(oldest) {
+		i++
+	}
+
+	source.chunks = source.chunks[i:]
+}
+
+// hold keeps the data received from the time on, past the keep duration,
+// until released. A clip waiting for the audio after it holds its start.
+func (r *replayBuffer) hold(from time.Time) func() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.holds[&from] = struct{}{}
+
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		delete(r.holds, &from)
+	}
+}
+
+// clip joins the data of the connection received between from and to. Ogg
+// streams cannot be decoded without their headers, the data starts with the
+// ones of its first chunk.
+func (r *replayBuffer) clip(conn *connection, from, to time.Time) (Clip, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var source *replaySource
+
+	for _, s := range r.sources {
+		if s.conn == conn {
+			source = s
+		}
+	}
+
+	if source == nil {
+		return Clip{}, ErrStreamChanged
+	}
+
+	var data []byte
+
+	for _, chunk := range source.chunks {
+		if !chunk.at.Before(from) && chunk.at.Before(to) {
+			if len(data) == 0 {
+				data = append(data, chunk.headers...)
+			}
+
+			data = append(data, chunk.data...)
+		}
+	}
+
+	if len(data) == 0 {
+		return Clip{}, ErrReplayNotEnough
+	}
+
+	return Clip{Stream: source.stream, Format: source.format, Data: data}, nil
+}
@@ -0,0 +1,172 @@
+package radio
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+const (
+	resamplerChunkSize = 4096
+)
+
+// resampler converts the decoder output to the sample rate and the number of
+// channels of the output. Linear interpolation is used, which is cheap
+// enough for a Raspberry Pi and transparent for the usual 44.1/48 kHz pairs.
+type resampler struct {
+	decoder    Decoder
+	sampleRate int
+	channels   int
+	buffer     []byte
+	pending    []byte
+	out        []byte
+	frames     []float32
+	prev       []float32
+	pos        float64
+	srcRate    int
+	srcChans   int
+}
+
+func newResampler(decoder Decoder, sampleRate int, channels int) *resampler {
+	return &resampler{
+		decoder:    decoder,
+		sampleRate: sampleRate,
+		channels:   channels,
+		buffer:     make([]byte, resamplerChunkSize),
+	}
+}
+
+func (r *resampler) Read(p []byte) (int, error) {
+	for len(r.out) == 0 {
+		n, err := r.decoder.Read(r.buffer)
+		if n > 0 {
+			r.convert(r.buffer[:n])
+		}
+
+		if err != nil {
+			if len(r.out) > 0 {
+				break
+			}
+
+			return 0, err
+		}
+
+		if n == 0 {
+			return 0, nil
+		}
+	}
+
+	n := copy(p, r.out)
+	r.out = r.out[n:]
+
+	return n, nil
+}
+
+func (r *resampler) convert(data []byte) {
+	srcRate, srcChans := r.decoder.SampleRate(), r.decoder.Channels()
+	if srcRate <= 0 || srcChans <= 0 {
+		return
+	}
+
+	if srcRate != r.srcRate || srcChans != r.srcChans {
+		r.srcRate, r.srcChans = srcRate, srcChans
+		r.pending = r.pending[:0]
+		r.prev = nil
+		r.pos = 0
+	}
+
+	if srcRate == r.sampleRate && srcChans == r.channels && len(r.pending) == 0 && len(data)%(2*srcChans) == 0 {
+		r.out = append(r.out[:0], data...)
+
+		return
+	}
+
+	r.pending = append(r.pending, data...)
+
+	frameSize := 2 * srcChans
+	count := len(r.pending) / frameSize
+
+	r.frames = r.frames[:0]
+	r.frames = append(r.frames, r.prev...)
+
+	for i := 0; i < count; i++ {
+		r.frames = r.remix(r.frames, r.pending[i*frameSize:(i+1)*frameSize], srcChans)
+	}
+
+	r.pending = append(r.pending[:0], r.pending[count*frameSize:]...)
+
+	if srcRate == r.sampleRate {
+		r.out = r.encode(r.out[:0], r.frames)
+
+		return
+	}
+
+	r.out = r.interpolate(r.out[:0], float64(srcRate)/float64(r.sampleRate))
+}
+
+func (r *resampler) remix(frames []float32, frame []byte, srcChans int) []float32 {
+	if r.channels == 1 {
+		var sum float32
+		for c := 0; c < srcChans; c++ {
+			sum += float32(int16(binary.LittleEndian.Uint16(frame[2*c:])))
+		}
+
+		return append(frames, sum/float32(srcChans))
+	}
+
+	for c := 0; c < r.channels; c++ {
+		s := c % srcChans
+		frames = append(frames, float32(int16(binary.LittleEndian.Uint16(frame[2*s:]))))
+	}
+
+	return frames
+}
+
+// interpolate keeps the last input frame and the fractional position between
+// calls, so chunk boundaries do not produce clicks.
+func (r *resampler) interpolate(out []byte, step float64) []byte {
+	count := len(r.frames) / r.channels
+	if count < 2 {
+		r.prev = append(r.prev[:0], r.frames...)
+
+		return out
+	}
+
+	pos := r.pos
+	for {
+		i := int(pos)
+		if i+1 >= count {
+			break
+		}
+
+		frac := float32(pos - float64(i))
+		a := r.frames[i*r.channels : (i+1)*r.channels]
+		b := r.frames[(i+1)*r.channels : (i+2)*r.channels]
+
+		for c := 0; c < r.channels; c++ {
+			out = appendSample(out, a[c]+(b[c]-a[c])*frac)
+		}
+
+		pos += step
+	}
+
+	r.pos = pos - float64(count-1)
+	r.prev = append(r.prev[:0], r.frames[(count-1)*r.channels:]...)
+
+	return out
+}
+
+func (r *resampler) encode(out []byte, frames []float32) []byte {
+	r.prev = r.prev[:0]
+
+	for _, sample := range frames {
+		out = appendSample(out, sample)
+	}
+
+	return out
+}
+
+func appendSample(out []byte, sample float32) []byte {
+	v := int16(math.Max(math.MinInt16, math.Min(math.MaxInt16, math.Round(float64(sample)))))
+
+	return append(out, byte(v), byte(v>>8))
+}
@@ -0,0 +1,261 @@
+package radio
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	mixerBlockDuration = 20 * time.Millisecond
+)
+
+var ErrSinkClosed = errors.New("sink closed")
+
+// Sink is the audio output of the player. It plays S16LE PCM streams at the
+// context sample rate and channels, mixing the streams playing at the same
+// time. The player suspends the sink when it stops and resumes it before the
+// first stream plays again.
+type Sink interface {
+	NewStream(source io.Reader) SinkStream
+	Resume() error
+	Suspend() error
+	Err() error
+}
+
+// SinkStream is a stream of a sink, it is silent until Play is called.
+type SinkStream interface {
+	Play()
+	Volume() float64
+	SetVolume(volume float64)
+	Close() error
+}
+
+// mixerSink mixes its streams in real time and writes the PCM to a writer,
+// standing in for a sound card. A blocking writer takes the audio as it is
+// played and paces the mixing itself, the ticker paces it otherwise.
+type mixerSink struct {
+	writer    io.Writer
+	blocking  bool
+	streams   map[*mixerStream]struct{}
+	suspended bool
+	started   bool
+	closed    bool
+	err       error
+	onSuspend func() error
+	onClose   func() error
+	stop      chan struct{}
+	done      chan struct{}
+	mu        sync.Mutex
+	writeMu   sync.Mutex
+}
+
+func newMixerSink(writer io.Writer) *mixerSink {
+	return &mixerSink{
+		writer:    writer,
+		streams:   make(map[*mixerStream]struct{}),
+		suspended: true,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+func (s *mixerSink) NewStream(source io.Reader) SinkStream {
+	stream := &mixerStream{sink: s, source: source, volume: 1}
+
+	s.mu.Lock()
+	s.streams[stream] = struct{}{}
+	s.mu.Unlock()
+
+	return stream
+}
+
+func (s *mixerSink) Resume() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ErrSinkClosed
+	}
+
+	s.suspended = false
+	if !s.started {
+		s.started = true
+
+		go s.run()
+	}
+
+	return nil
+}
+
+func (s *mixerSink) Suspend() error {
+	s.mu.Lock()
+	s.suspended = true
+	s.mu.Unlock()
+
+	if s.onSuspend == nil {
+		return nil
+	}
+
+	return s.onSuspend()
+}
+
+func (s *mixerSink) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.err
+}
+
+// Close stops the mixing once the block being mixed is written, then closes
+// the output. The sink can't be resumed afterwards.
+func (s *mixerSink) Close() error {
+	s.mu.Lock()
+
+	if s.closed {
+		s.mu.Unlock()
+
+		return nil
+	}
+
+	s.closed = true
+	s.suspended = true
+	started := s.started
+	close(s.stop)
+
+	s.mu.Unlock()
+
+	if started {
+		<-s.done
+	}
+
+	if s.onClose == nil {
+		return nil
+	}
+
+	return s.onClose()
+}
+
+func (s *mixerSink) run() {
+	frames := int(mixerBlockDuration.Seconds() * contextSampleRate)
+	block := make([]byte, frames*contextNumChannels*2)
+	mix := make([]float64, frames*contextNumChannels)
+	out := make([]byte, len(block))
+
+	defer close(s.done)
+
+	ticker := time.NewTicker(mixerBlockDuration)
+	defer ticker.Stop()
+
+	idle := true
+
+	for {
+		if idle || !s.blocking {
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+			}
+		} else {
+			select {
+			case <-s.stop:
+				return
+			default:
+			}
+		}
+
+		s.mu.Lock()
+		streams := make([]*mixerStream, 0, len(s.streams))
+		for stream := range s.streams {
+			if stream.playing {
+				streams = append(streams, stream)
+			}
+		}
+		suspended := s.suspended
+		s.mu.Unlock()
+
+		idle = suspended || len(streams) == 0
+		if idle {
+			continue
+		}
+
+		for i := range mix {
+			mix[i] = 0
+		}
+
+		for _, stream := range streams {
+			n, err := io.ReadFull(stream.source, block)
+			n -= n % 2
+
+			s.mu.Lock()
+			volume := stream.volume
+			if err != nil {
+				stream.playing = false
+			}
+			s.mu.Unlock()
+
+			for i := 0; i < n; i += 2 {
+				mix[i/2] += float64(int16(binary.LittleEndian.Uint16(block[i:]))) * volume
+			}
+		}
+
+		for i, v := range mix {
+			binary.LittleEndian.PutUint16(out[2*i:], uint16(int16(math.Max(-32768, math.Min(v, 32767)))))
+		}
+
+		// The writer may block, the streams stay controllable meanwhile.
+		s.writeMu.Lock()
+		_, err := s.writer.Write(out)
+		s.writeMu.Unlock()
+
+		if err != nil {
+			s.mu.Lock()
+			s.err = err
+			s.suspended = true
+			s.mu.Unlock()
+		}
+	}
+}
+
+type mixerStream struct {
+	sink    *mixerSink
+	source  io.Reader
+	volume  float64
+	playing bool
+}
+
+func (s *mixerStream) Play() {
+	s.sink.mu.Lock()
+	defer s.sink.mu.Unlock()
+
+	if _, ok := s.sink.streams[s]; ok {
+		s.playing = true
+	}
+}
+
+func (s *mixerStream) Volume() float64 {
+	s.sink.mu.Lock()
+	defer s.sink.mu.Unlock()
+
+	return s.volume
+}
+
+func (s *mixerStream) SetVolume(volume float64) {
+	s.sink.mu.Lock()
+	defer s.sink.mu.Unlock()
+
+	s.volume = volume
+}
+
+func (s *mixerStream) Close() error {
+	s.sink.mu.Lock()
+	defer s.sink.mu.Unlock()
+
+	s.playing = false
+	delete(s.sink.streams, s)
+
+	return nil
+}
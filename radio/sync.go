@@ -0,0 +1,275 @@
+package radio
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	syncMagic = "RSYNC1\n"
+
+	syncMessageAudio = 'A'
+	syncMessagePing  = 'P'
+	syncMessagePong  = 'Q'
+
+	syncMaxBlockSize = 1 << 20
+
+	// syncTolerance is how far the playback may drift from the timestamps
+	// before it is realigned, by skipping audio or inserting silence.
+	syncTolerance = 20 * time.Millisecond
+
+	syncPingInterval = 2 * time.Second
+	syncClockSamples = 16
+	syncWriteTimeout = 5 * time.Second
+
+	// syncReadTimeout is how long a follower waits for a message, the leader
+	// answers the pings even while it is not playing.
+	syncReadTimeout = 3 * syncPingInterval
+)
+
+var ErrSyncProtocol = errors.New("sync protocol error")
+
+// SyncPolicy controls the synchronized playback of a group of players. The
+// leader sends its audio Delay ahead of the time it is played at, covering
+// the network. Latency is the delay of the output of the device, from the
+// audio read to it being heard, which is played that much earlier.
+type SyncPolicy struct {
+	Delay   time.Duration
+	Latency time.Duration
+}
+
+func DefaultSyncPolicy() SyncPolicy {
+	return SyncPolicy{Delay: 500 * time.Millisecond}
+}
+
+// The messages between the leader and the followers, after the magic sent by
+// the follower:
+//
+//	audio: 'A', play time (int64, unix ns of the leader), size (uint32), PCM
+//	ping:  'P', follower time (int64)
+//	pong:  'Q', follower time of the ping (int64), leader time (int64)
+//
+// The integers are big-endian, the PCM is S16LE at the context sample rate
+// and channels.
+
+func writeSyncAudio(w io.Writer, at time.Time, data []byte) error {
+	header := make([]byte, 13)
+	header[0] = syncMessageAudio
+	binary.BigEndian.PutUint64(header[1:], uint64(at.UnixNano()))
+	binary.BigEndian.PutUint32(header[9:], uint32(len(data)))
+
+	_, err := w.Write(append(header, data...))
+
+	return err
+}
+
+func writeSyncPing(w io.Writer, sent time.Time) error {
+	message := make([]byte, 9)
+	message[0] = syncMessagePing
+	binary.BigEndian.PutUint64(message[1:], uint64(sent.UnixNano()))
+
+	_, err := w.Write(message)
+
+	return err
+}
+
+func writeSyncPong(w io.Writer, sent int64, now time.Time) error {
+	message := make([]byte, 17)
+	message[0] = syncMessagePong
+	binary.BigEndian.PutUint64(message[1:], uint64(sent))
+	binary.BigEndian.PutUint64(message[9:], uint64(now.UnixNano()))
+
+	_, err := w.Write(message)
+
+	return err
+}
+
+func readSyncInt(r io.Reader) (int64, error) {
+	var b [8]byte
+
+	_, err := io.ReadFull(r, b[:])
+	if err != nil {
+		return 0, err
+	}
+
+	return int64(binary.BigEndian.Uint64(b[:])), nil
+}
+
+func readSyncBlock(r io.Reader) ([]byte, error) {
+	var b [4]byte
+
+	_, err := io.ReadFull(r, b[:])
+	if err != nil {
+		return nil, err
+	}
+
+	size := binary.BigEndian.Uint32(b[:])
+	if size > syncMaxBlockSize {
+		return nil, fmt.Errorf("%w: block of %d bytes", ErrSyncProtocol, size)
+	}
+
+	data := make([]byte, size)
+
+	_, err = io.ReadFull(r, data)
+
+	return data, err
+}
+
+// syncClock estimates the offset of the leader clock from the pings. The
+// sample with the shortest round trip of the recent ones is the least
+// skewed by the queueing on the way.
+type syncClock struct {
+	samples []syncClockSample
+	mu      sync.Mutex
+}
+
+type syncClockSample struct {
+	offset    time.Duration
+	roundTrip time.Duration
+}
+
+func (c *syncClock) add(sent, received time.Time, leader int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	roundTrip := received.Sub(sent)
+	middle := sent.Add(roundTrip / 2)
+
+	c.samples = append(c.samples, syncClockSample{
+		offset:    time.Unix(0, leader).Sub(middle),
+		roundTrip: roundTrip,
+	})
+
+	if len(c.samples) > syncClockSamples {
+		c.samples = c.samples[len(c.samples)-syncClockSamples:]
+	}
+}
+
+// offset is how far the leader clock is ahead, false until a pong is back.
+func (c *syncClock) offset() (time.Duration, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.samples) == 0 {
+		return 0, false
+	}
+
+	best := c.samples[0]
+	for _, sample := range c.samples[1:] {
+		if sample.roundTrip < best.roundTrip {
+			best = sample
+		}
+	}
+
+	return best.offset, true
+}
+
+type syncBlock struct {
+	at   time.Time
+	data []byte
+}
+
+// syncPlayout is the source of the output, playing the blocks at their time
+// on the local clock. The output reads ahead of what is heard by the
+// latency, so the block heard now is the one read latency earlier. The
+// playback goes on continuously, and is only realigned once it drifts by
+// more than the tolerance. It never blocks, silence is played without audio.
+type syncPlayout struct {
+	latency     time.Duration
+	blocks      []syncBlock
+	offset      int
+	frameSize   int
+	bytesPerSec int
+	mu          sync.Mutex
+}
+
+func newSyncPlayout(latency time.Duration) *syncPlayout {
+	frameSize := 2 * contextNumChannels
+
+	return &syncPlayout{latency: latency, frameSize: frameSize, bytesPerSec: contextSampleRate * frameSize}
+}
+
+// push queues a block, the blocks too late to be played are dropped.
+func (p *syncPlayout) push(at time.Time, data []byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if at.Add(p.duration(len(data))).Before(time.Now().Add(p.latency)) {
+		return
+	}
+
+	p.blocks = append(p.blocks, syncBlock{at: at, data: data})
+}
+
+func (p *syncPlayout) Read(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	n := len(b) - len(b)%p.frameSize
+	heard := time.Now().Add(p.latency)
+	written := 0
+
+	if len(p.blocks) > 0 {
+		drift := heard.Sub(p.blocks[0].at.Add(p.duration(p.offset)))
+
+		if drift > syncTolerance {
+			p.skip(p.bytes(drift))
+		}
+
+		if drift < -syncTolerance {
+			written = p.bytes(-drift)
+			if written > n {
+				written = n
+			}
+		}
+	}
+
+	for i := range b[:written] {
+		b[i] = 0
+	}
+
+	for written < n && len(p.blocks) > 0 {
+		block := p.blocks[0]
+
+		copied := copy(b[written:n], block.data[p.offset:])
+		written += copied
+		p.offset += copied
+
+		if p.offset == len(block.data) {
+			p.blocks, p.offset = p.blocks[1:], 0
+		}
+	}
+
+	for i := range b[written:n] {
+		b[written+i] = 0
+	}
+
+	return n, nil
+}
+
+// skip must be called with the mutex held.
+func (p *syncPlayout) skip(n int) {
+	for n > 0 && len(p.blocks) > 0 {
+		rest := len(p.blocks[0].data) - p.offset
+		if n < rest {
+			p.offset += n
+
+			return
+		}
+
+		n -= rest
+		p.blocks, p.offset = p.blocks[1:], 0
+	}
+}
+
+func (p *syncPlayout) duration(n int) time.Duration {
+	return time.Duration(n) * time.Second / time.Duration(p.bytesPerSec)
+}
+
+func (p *syncPlayout) bytes(d time.Duration) int {
+	return frames(d, p.bytesPerSec, p.frameSize)
+}
@@ -0,0 +1,241 @@
+package radio
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+// SyncFollower plays the audio of a leader to its sink, at the time given
+// by the leader, on the leader clock estimated over the connection.
+type SyncFollower struct {
+	address         string
+	sink            Sink
+	policy          SyncPolicy
+	reconnectPolicy ReconnectPolicy
+	playout         *syncPlayout
+	clock           *syncClock
+	conn            net.Conn
+	closed          bool
+	stop            chan struct{}
+	mu              sync.Mutex
+}
+
+func NewSyncFollower(address string, sink Sink, policy SyncPolicy) *SyncFollower {
+	return &SyncFollower{
+		address:         address,
+		sink:            sink,
+		policy:          policy,
+		reconnectPolicy: DefaultReconnectPolicy(),
+		playout:         newSyncPlayout(policy.Latency),
+		clock:           &syncClock{},
+		stop:            make(chan struct{}),
+	}
+}
+
+// SetReconnectPolicy sets the delays between the connection attempts. The
+// follower keeps waiting for its leader, the attempts are not limited.
+func (f *SyncFollower) SetReconnectPolicy(policy ReconnectPolicy) {
+	f.reconnectPolicy = policy
+}
+
+// Run plays until Close is called.
+func (f *SyncFollower) Run() error {
+	err := f.sink.Resume()
+	if err != nil {
+		return err
+	}
+
+	stream := f.sink.NewStream(f.playout)
+	stream.Play()
+
+	defer func() {
+		_ = stream.Close()
+		_ = f.sink.Suspend()
+	}()
+
+	attempt := 0
+
+	for {
+		started := time.Now()
+
+		err = f.follow()
+
+		select {
+		case <-f.stop:
+			return nil
+		default:
+		}
+
+		// A connection which played for a while starts the backoff over.
+		if time.Since(started) > f.reconnectPolicy.MaxDelay {
+			attempt = 0
+		}
+
+		if attempt < 32 {
+			attempt++
+		}
+
+		delay := f.reconnectPolicy.delay(attempt)
+
+		log.Printf("Sync leader connection lost, reconnecting in %s (address: %s): %s\n", delay, f.address, err)
+
+		select {
+		case <-f.stop:
+			return nil
+		case <-time.After(delay):
+		}
+	}
+}
+
+func (f *SyncFollower) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return nil
+	}
+
+	f.closed = true
+	close(f.stop)
+
+	if f.conn != nil {
+		return f.conn.Close()
+	}
+
+	return nil
+}
+
+func (f *SyncFollower) follow() error {
+	conn, err := net.DialTimeout("tcp", f.address, syncWriteTimeout)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	if f.closed {
+		f.mu.Unlock()
+		_ = conn.Close()
+
+		return nil
+	}
+	f.conn = conn
+	f.mu.Unlock()
+
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	var writeMu sync.Mutex
+
+	write := func(message func(w io.Writer) error) error {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+
+		_ = conn.SetWriteDeadline(time.Now().Add(syncWriteTimeout))
+
+		return message(conn)
+	}
+
+	err = write(func(w io.Writer) error {
+		_, err := io.WriteString(w, syncMagic)
+
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Sync leader connected (address: %s)\n", f.address)
+
+	done := make(chan struct{})
+	defer close(done)
+
+	go f.ping(conn, write, done)
+
+	return f.receive(conn, bufio.NewReader(conn))
+}
+
+// ping measures the clock a few times in a row at first, to start from a
+// good estimate, then at the interval. A failed ping closes the connection,
+// which ends the receiving.
+func (f *SyncFollower) ping(conn net.Conn, write func(message func(w io.Writer) error) error, done <-chan struct{}) {
+	interval := syncPingInterval / 20
+
+	for i := 0; ; i++ {
+		if i == syncClockSamples/2 {
+			interval = syncPingInterval
+		}
+
+		err := write(func(w io.Writer) error {
+			return writeSyncPing(w, time.Now())
+		})
+		if err != nil {
+			_ = conn.Close()
+
+			return
+		}
+
+		select {
+		case <-done:
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// receive reads the messages of the leader, a leader gone silent is taken
+// for lost.
+func (f *SyncFollower) receive(conn net.Conn, reader *bufio.Reader) error {
+	frameSize := 2 * contextNumChannels
+
+	for {
+		_ = conn.SetReadDeadline(time.Now().Add(syncReadTimeout))
+
+		kind, err := reader.ReadByte()
+		if err != nil {
+			return err
+		}
+
+		switch kind {
+		case syncMessageAudio:
+			at, err := readSyncInt(reader)
+			if err != nil {
+				return err
+			}
+
+			data, err := readSyncBlock(reader)
+			if err != nil {
+				return err
+			}
+
+			if len(data)%frameSize != 0 {
+				return fmt.Errorf("%w: block of %d bytes", ErrSyncProtocol, len(data))
+			}
+
+			// The audio waits for the clock.
+			offset, ok := f.clock.offset()
+			if ok {
+				f.playout.push(time.Unix(0, at).Add(-offset), data)
+			}
+		case syncMessagePong:
+			sent, err := readSyncInt(reader)
+			if err != nil {
+				return err
+			}
+
+			leader, err := readSyncInt(reader)
+			if err != nil {
+				return err
+			}
+
+			f.clock.add(time.Unix(0, sent), time.Now(), leader)
+		default:
+			return fmt.Errorf("%w: message %q", ErrSyncProtocol, kind)
+		}
+	}
+}
@@ -0,0 +1,288 @@
+package radio
+
+import (
+	"bufio"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+// syncFollowerQueueSize is how many blocks a follower may fall behind, a few
+// seconds of audio, before it is dropped.
+const syncFollowerQueueSize = 256
+
+// SyncLeader is the sink of the leader of a group. It mixes the streams of
+// the player, sends the audio to the followers connected with the time to
+// play it at, and plays it at that time to its output. The followers play
+// whatever the leader plays, its station, volume and power included.
+type SyncLeader struct {
+	*mixerSink
+	output    Sink
+	stream    SinkStream
+	playout   *syncPlayout
+	policy    SyncPolicy
+	listener  net.Listener
+	followers map[*syncFollowerConn]struct{}
+	next      time.Time
+	closed    bool
+	mu        sync.Mutex
+}
+
+type syncFollowerConn struct {
+	conn     net.Conn
+	messages chan func(w io.Writer) error
+	done     chan struct{}
+	once     sync.Once
+}
+
+// NewSyncLeader listens for the followers on the address and plays to the
+// output.
+func NewSyncLeader(output Sink, address string, policy SyncPolicy) (*SyncLeader, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &SyncLeader{
+		output:    output,
+		playout:   newSyncPlayout(policy.Latency),
+		policy:    policy,
+		listener:  listener,
+		followers: make(map[*syncFollowerConn]struct{}),
+	}
+
+	l.mixerSink = newMixerSink(writerFunc(l.write))
+	l.stream = output.NewStream(l.playout)
+
+	go l.accept()
+
+	return l, nil
+}
+
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) {
+	return f(p)
+}
+
+func (l *SyncLeader) Resume() error {
+	err := l.output.Resume()
+	if err != nil {
+		return err
+	}
+
+	l.stream.Play()
+
+	return l.mixerSink.Resume()
+}
+
+func (l *SyncLeader) Suspend() error {
+	err := l.mixerSink.Suspend()
+	if err != nil {
+		return err
+	}
+
+	return l.output.Suspend()
+}
+
+func (l *SyncLeader) Err() error {
+	err := l.mixerSink.Err()
+	if err != nil {
+		return err
+	}
+
+	return l.output.Err()
+}
+
+// Followers returns the addresses of the followers connected.
+func (l *SyncLeader) Followers() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	addresses := make([]string, 0, len(l.followers))
+	for f := range l.followers {
+		addresses = append(addresses, f.conn.RemoteAddr().String())
+	}
+
+	return addresses
+}
+
+// Close disconnects the followers, stops mixing and listening and closes the
+// output.
+func (l *SyncLeader) Close() error {
+	_ = l.mixerSink.Close()
+
+	l.mu.Lock()
+	l.closed = true
+	followers := make([]*syncFollowerConn, 0, len(l.followers))
+	for f := range l.followers {
+		followers = append(followers, f)
+	}
+	l.mu.Unlock()
+
+	for _, f := range followers {
+		f.close()
+	}
+
+	err := l.listener.Close()
+
+	// The output of the leader is finished with it, like a WAV file.
+	if closer, ok := l.output.(io.Closer); ok {
+		closeErr := closer.Close()
+		if err == nil {
+			err = closeErr
+		}
+	}
+
+	return err
+}
+
+// write stamps a block of the mixer with its play time. The blocks follow
+// each other, the timeline restarts after a pause or when the mixer falls
+// behind.
+func (l *SyncLeader) write(p []byte) (int, error) {
+	data := append([]byte(nil), p...)
+	now := time.Now()
+
+	l.mu.Lock()
+
+	at := l.next
+	if drift := now.Add(l.policy.Delay).Sub(at); at.IsZero() || drift > syncTolerance || drift < -syncTolerance {
+		at = now.Add(l.policy.Delay)
+	}
+
+	l.next = at.Add(l.playout.duration(len(data)))
+
+	for f := range l.followers {
+		f.send(func(w io.Writer) error {
+			return writeSyncAudio(w, at, data)
+		})
+	}
+
+	l.mu.Unlock()
+
+	l.playout.push(at, data)
+
+	return len(p), nil
+}
+
+func (l *SyncLeader) accept() {
+	for {
+		conn, err := l.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		go l.serve(conn)
+	}
+}
+
+func (l *SyncLeader) serve(conn net.Conn) {
+	reader := bufio.NewReader(conn)
+
+	magic := make([]byte, len(syncMagic))
+
+	_ = conn.SetReadDeadline(time.Now().Add(syncWriteTimeout))
+
+	_, err := io.ReadFull(reader, magic)
+	if err != nil || string(magic) != syncMagic {
+		_ = conn.Close()
+
+		return
+	}
+
+	_ = conn.SetReadDeadline(time.Time{})
+
+	f := &syncFollowerConn{
+		conn:     conn,
+		messages: make(chan func(w io.Writer) error, syncFollowerQueueSize),
+		done:     make(chan struct{}),
+	}
+
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		_ = conn.Close()
+
+		return
+	}
+	l.followers[f] = struct{}{}
+	l.mu.Unlock()
+
+	log.Printf("Sync follower connected (address: %s)\n", conn.RemoteAddr())
+
+	go f.run()
+
+	for {
+		var kind [1]byte
+
+		_, err = io.ReadFull(reader, kind[:])
+		if err != nil {
+			break
+		}
+
+		if kind[0] != syncMessagePing {
+			break
+		}
+
+		sent, err := readSyncInt(reader)
+		if err != nil {
+			break
+		}
+
+		f.send(func(w io.Writer) error {
+			return writeSyncPong(w, sent, time.Now())
+		})
+	}
+
+	f.close()
+
+	l.mu.Lock()
+	delete(l.followers, f)
+	l.mu.Unlock()
+
+	log.Printf("Sync follower disconnected (address: %s)\n", conn.RemoteAddr())
+}
+
+// send queues a message, a follower too slow to take it is dropped.
+func (f *syncFollowerConn) send(message func(w io.Writer) error) {
+	select {
+	case <-f.done:
+	case f.messages <- message:
+	default:
+		f.close()
+	}
+}
+
+func (f *syncFollowerConn) run() {
+	writer := bufio.NewWriter(f.conn)
+
+	for {
+		select {
+		case <-f.done:
+			return
+		case message := <-f.messages:
+			_ = f.conn.SetWriteDeadline(time.Now().Add(syncWriteTimeout))
+
+			err := message(writer)
+			if err == nil && len(f.messages) == 0 {
+				err = writer.Flush()
+			}
+
+			if err != nil {
+				f.close()
+
+				return
+			}
+		}
+	}
+}
+
+func (f *syncFollowerConn) close() {
+	f.once.Do(func() {
+		close(f.done)
+		_ = f.conn.Close()
+	})
+}
@@ -0,0 +1,114 @@
+package radio
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// tapHandler receives the data read from the stream, with the Ogg headers
+// needed to decode it from its start, if it is an Ogg stream.
+type tapHandler func(data, headers []byte)
+
+// streamTap passes the raw data read from the stream, without the ICY
+// metadata, to its subscribers. Ogg streams can only be decoded with the
+// headers of their bitstreams, their data is passed in whole pages along with
+// the current headers, so a subscriber can start from any of them.
+type streamTap struct {
+	reader   io.ReadCloser
+	sniff    []byte
+	sniffed  bool
+	ogg      *oggPages
+	handlers map[int]tapHandler
+	next     int
+	done     chan struct{}
+	once     sync.Once
+	mu       sync.Mutex
+}
+
+func newStreamTap(reader io.ReadCloser) *streamTap {
+	return &streamTap{reader: reader, handlers: make(map[int]tapHandler), done: make(chan struct{})}
+}
+
+// Done is closed once the stream is closed.
+func (t *streamTap) Done() <-chan struct{} {
+	return t.done
+}
+
+// Subscribe returns a function to unsubscribe. The handler is called on the
+// reading goroutine and must not keep the data, the headers are not changed
+// afterwards.
+func (t *streamTap) Subscribe(handler tapHandler) func() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	id := t.next
+	t.next++
+	t.handlers[id] = handler
+
+	return func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+
+		delete(t.handlers, id)
+	}
+}
+
+func (t *streamTap) Read(p []byte) (int, error) {
+	n, err := t.reader.Read(p)
+	if n == 0 {
+		return n, err
+	}
+
+	t.mu.Lock()
+
+	data, headers := t.split(p[:n])
+
+	handlers := make([]tapHandler, 0, len(t.handlers))
+	for _, handler := range t.handlers {
+		handlers = append(handlers, handler)
+	}
+
+	t.mu.Unlock()
+
+	if len(data) > 0 {
+		for _, handler := range handlers {
+			handler(data, headers)
+		}
+	}
+
+	return n, err
+}
+
+// split must be called with the mutex held. It holds the data back until the
+// format is known, then until the Ogg pages are whole.
+func (t *streamTap) split(data []byte) ([]byte, []byte) {
+	if !t.sniffed {
+		t.sniff = append(t.sniff, data...)
+
+		capture := []byte("OggS")
+		if len(t.sniff) < len(capture) && bytes.HasPrefix(capture, t.sniff) {
+			return nil, nil
+		}
+
+		if bytes.HasPrefix(t.sniff, capture) {
+			t.ogg = newOggPages()
+		}
+
+		data, t.sniff, t.sniffed = t.sniff, nil, true
+	}
+
+	if t.ogg == nil {
+		return data, nil
+	}
+
+	return t.ogg.Write(data)
+}
+
+func (t *streamTap) Close() error {
+	t.once.Do(func() {
+		close(t.done)
+	})
+
+	return t.reader.Close()
+}
@@ -0,0 +1,58 @@
+package radio
+
+import (
+	"github.com/jfreymuth/vorbis"
+)
+
+type vorbisCodec struct {
+	decoder vorbis.Decoder
+	buffer  []float32
+}
+
+func newVorbisCodec(header []byte) (oggCodec, error) {
+	c := &vorbisCodec{}
+
+	err := c.decoder.ReadHeader(header)
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (c *vorbisCodec) Decode(packet []byte) ([]byte, error) {
+	if vorbis.IsHeader(packet) {
+		return nil, c.decoder.ReadHeader(packet)
+	}
+
+	if !c.decoder.HeadersRead() {
+		return nil, nil
+	}
+
+	if c.buffer == nil {
+		c.buffer = make([]float32, c.decoder.BufferSize())
+	}
+
+	samples, err := c.decoder.DecodeInto(packet, c.buffer)
+	if err != nil {
+		return nil, err
+	}
+
+	return encodePCM16(samples), nil
+}
+
+func (c *vorbisCodec) SampleRate() int {
+	return c.decoder.SampleRate()
+}
+
+func (c *vorbisCodec) Channels() int {
+	return c.decoder.Channels()
+}
+
+func (c *vorbisCodec) Bitrate() int {
+	return c.decoder.Bitrate.Nominal / 1000
+}
+
+func (c *vorbisCodec) Close() error {
+	return nil
+}
@@ -0,0 +1,201 @@
+package radio
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	watchdogCheckInterval = time.Second
+)
+
+var (
+	ErrStreamStalled = errors.New("stream stalled")
+	ErrStreamSilent  = errors.New("stream silent")
+)
+
+type WatchdogAlarm string
+
+const (
+	AlarmStalled WatchdogAlarm = "stalled"
+	AlarmSilent  WatchdogAlarm = "silent"
+)
+
+type WatchdogAction string
+
+const (
+	WatchdogReconnect WatchdogAction = "reconnect"
+	WatchdogSkip      WatchdogAction = "skip"
+	WatchdogReport    WatchdogAction = "report"
+)
+
+// ParseWatchdogAction returns the action of the name, rejecting unknown ones.
+func ParseWatchdogAction(name string) (WatchdogAction, error) {
+	switch action := WatchdogAction(name); action {
+	case WatchdogReconnect, WatchdogSkip, WatchdogReport:
+		return action, nil
+	default:
+		return "", fmt.Errorf("unknown watchdog action: %q", name)
+	}
+}
+
+type WatchdogEvent struct {
+	Alarm    WatchdogAlarm
+	Stream   string
+	Duration time.Duration
+	Action   WatchdogAction
+}
+
+type WatchdogHandler func(event WatchdogEvent)
+
+// WatchdogPolicy controls when a playing stream is considered stalled (no
+// decoded audio) or silent (peak level below SilenceThreshold dBFS), and what
+// the player does about it. A zero timeout disables the check.
+type WatchdogPolicy struct {
+	StallTimeout     time.Duration
+	StallAction      WatchdogAction
+	SilenceTimeout   time.Duration
+	SilenceThreshold float64
+	SilenceAction    WatchdogAction
+}
+
+func DefaultWatchdogPolicy() WatchdogPolicy {
+	return WatchdogPolicy{
+		StallTimeout:     15 * time.Second,
+		StallAction:      WatchdogReconnect,
+		SilenceTimeout:   2 * time.Minute,
+		SilenceThreshold: -60,
+		SilenceAction:    WatchdogReconnect,
+	}
+}
+
+func (p WatchdogPolicy) action(alarm WatchdogAlarm) WatchdogAction {
+	if alarm == AlarmStalled {
+		return p.StallAction
+	}
+
+	return p.SilenceAction
+}
+
+// watchdog watches the decoded S16LE audio on its way to the output and
+// raises an alarm once per stall or silence period.
+type watchdog struct {
+	reader    io.Reader
+	policy    WatchdogPolicy
+	threshold int
+	lastData  time.Time
+	lastSound time.Time
+	stalled   bool
+	silent    bool
+	alarms    chan WatchdogEvent
+	stop      chan struct{}
+	once      sync.Once
+	mu        sync.Mutex
+}
+
+func newWatchdog(reader io.Reader, policy WatchdogPolicy) *watchdog {
+	now := time.Now()
+
+	w := &watchdog{
+		reader:    reader,
+		policy:    policy,
+		threshold: int(math.Round(32768 * math.Pow(10, policy.SilenceThreshold/20))),
+		lastData:  now,
+		lastSound: now,
+		alarms:    make(chan WatchdogEvent, 1),
+		stop:      make(chan struct{}),
+	}
+
+	if policy.StallTimeout > 0 || policy.SilenceTimeout > 0 {
+		go w.run()
+	}
+
+	return w
+}
+
+func (w *watchdog) Read(p []byte) (int, error) {
+	n, err := w.reader.Read(p)
+	if n == 0 {
+		return n, err
+	}
+
+	loud := peakLevel(p[:n]) > w.threshold
+
+	w.mu.Lock()
+	w.lastData = time.Now()
+	w.stalled = false
+
+	if loud {
+		w.lastSound = w.lastData
+		w.silent = false
+	}
+	w.mu.Unlock()
+
+	return n, err
+}
+
+func (w *watchdog) Close() {
+	w.once.Do(func() {
+		close(w.stop)
+	})
+}
+
+func (w *watchdog) run() {
+	ticker := time.NewTicker(watchdogCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if event, ok := w.check(time.Now()); ok {
+				select {
+				case w.alarms <- event:
+				default:
+				}
+			}
+		case <-w.stop:
+			return
+		}
+	}
+}
+
+func (w *watchdog) check(now time.Time) (WatchdogEvent, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if d := now.Sub(w.lastData); w.policy.StallTimeout > 0 && d >= w.policy.StallTimeout && !w.stalled {
+		w.stalled = true
+
+		return WatchdogEvent{Alarm: AlarmStalled, Duration: d}, true
+	}
+
+	if d := now.Sub(w.lastSound); w.policy.SilenceTimeout > 0 && d >= w.policy.SilenceTimeout && !w.silent && !w.stalled {
+		w.silent = true
+
+		return WatchdogEvent{Alarm: AlarmSilent, Duration: d}, true
+	}
+
+	return WatchdogEvent{}, false
+}
+
+func peakLevel(data []byte) int {
+	peak := 0
+
+	for i := 0; i+1 < len(data); i += 2 {
+		sample := int(int16(binary.LittleEndian.Uint16(data[i:])))
+		if sample < 0 {
+			sample = -sample
+		}
+
+		if sample > peak {
+			peak = sample
+		}
+	}
+
+	return peak
+}
@@ -0,0 +1,175 @@
+package radio
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	wavHeaderSize = 44
+	// wavMaxDataSize keeps the RIFF size of the header within 32 bits, about
+	// 6.7 hours of audio.
+	wavMaxDataSize = (math.MaxUint32 - 36) / (contextNumChannels * 2) * (contextNumChannels * 2)
+)
+
+// wavFile writes a 16-bit PCM WAV file. The sizes in the header are updated
+// whenever the sink is suspended or closed, so the file is valid after each
+// stop. Before the sizes overflow, the file is finished and the audio goes on
+// in the next part, like "radio_2.wav".
+type wavFile struct {
+	path  string
+	file  *os.File
+	part  int
+	size  uint32
+	limit uint32
+	mu    sync.Mutex
+}
+
+// NewWAVSink returns a sink writing the audio to a WAV file in real time.
+func NewWAVSink(path string) (Sink, error) {
+	w := &wavFile{path: path, limit: wavMaxDataSize}
+
+	err := w.create()
+	if err != nil {
+		return nil, err
+	}
+
+	sink := newMixerSink(w)
+	sink.onSuspend = w.writeHeader
+	sink.onClose = w.close
+
+	return sink, nil
+}
+
+func (w *wavFile) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return 0, ErrSinkClosed
+	}
+
+	if uint64(w.size)+uint64(len(p)) > uint64(w.limit) {
+		err := w.next()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := w.file.Write(p)
+	w.size += uint32(n)
+
+	return n, err
+}
+
+func (w *wavFile) writeHeader() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+
+	return w.writeHeaderLocked()
+}
+
+// close finishes the header and closes the file.
+func (w *wavFile) close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+
+	err := w.writeHeaderLocked()
+
+	closeErr := w.file.Close()
+	if err == nil {
+		err = closeErr
+	}
+
+	w.file = nil
+
+	return err
+}
+
+// next must be called with the mutex held. It finishes the file and goes on
+// with the next part.
+func (w *wavFile) next() error {
+	err := w.writeHeaderLocked()
+	if err != nil {
+		return err
+	}
+
+	err = w.file.Close()
+	w.file = nil
+
+	if err != nil {
+		return err
+	}
+
+	w.part++
+
+	return w.create()
+}
+
+// create must be called with the mutex held.
+func (w *wavFile) create() error {
+	path := w.path
+	if w.part > 0 {
+		ext := filepath.Ext(path)
+		path = fmt.Sprintf("%s_%d%s", strings.TrimSuffix(path, ext), w.part+1, ext)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	w.file = file
+	w.size = 0
+
+	err = w.writeHeaderLocked()
+	if err != nil {
+		_ = file.Close()
+		w.file = nil
+
+		return err
+	}
+
+	return nil
+}
+
+// writeHeaderLocked must be called with the mutex held.
+func (w *wavFile) writeHeaderLocked() error {
+	header := make([]byte, wavHeaderSize)
+
+	copy(header[0:], "RIFF")
+	binary.LittleEndian.PutUint32(header[4:], 36+w.size)
+	copy(header[8:], "WAVEfmt ")
+	binary.LittleEndian.PutUint32(header[16:], 16)
+	binary.LittleEndian.PutUint16(header[20:], 1)
+	binary.LittleEndian.PutUint16(header[22:], contextNumChannels)
+	binary.LittleEndian.PutUint32(header[24:], contextSampleRate)
+	binary.LittleEndian.PutUint32(header[28:], contextSampleRate*contextNumChannels*2)
+	binary.LittleEndian.PutUint16(header[32:], contextNumChannels*2)
+	binary.LittleEndian.PutUint16(header[34:], 16)
+	copy(header[36:], "data")
+	binary.LittleEndian.PutUint32(header[40:], w.size)
+
+	_, err := w.file.WriteAt(header, 0)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.file.Seek(0, io.SeekEnd)
+
+	return err
+}
@@ -0,0 +1,84 @@
+package radio
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func readWAV(t *testing.T, path string) (riffSize, dataSize uint32, data []byte) {
+	t.Helper()
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(b) < wavHeaderSize {
+		t.Fatalf("%s: short file: %d bytes", path, len(b))
+	}
+
+	return binary.LittleEndian.Uint32(b[4:]), binary.LittleEndian.Uint32(b[40:]), b[wavHeaderSize:]
+}
+
+func TestWAVSinkCloseFinishesHeader(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "radio.wav")
+
+	sink, err := NewWAVSink(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stream := sink.NewStream(constantSource(1000))
+	stream.Play()
+
+	err = sink.Resume()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(10 * mixerBlockDuration)
+
+	// Closed without a suspend first.
+	err = sink.(*mixerSink).Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	riffSize, dataSize, data := readWAV(t, path)
+	if dataSize == 0 || int(dataSize) != len(data) || riffSize != 36+dataSize {
+		t.Fatalf("got RIFF size %d, data size %d for %d bytes of data", riffSize, dataSize, len(data))
+	}
+}
+
+func TestWAVFileGoesOnInNextPart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "radio.wav")
+
+	w := &wavFile{path: path, limit: 8}
+
+	err := w.create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		_, err = w.Write([]byte{1, 2, 3, 4, 5, 6})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = w.close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"radio.wav", "radio_2.wav", "radio_3.wav"} {
+		riffSize, dataSize, data := readWAV(t, filepath.Join(filepath.Dir(path), name))
+		if dataSize != 6 || len(data) != 6 || riffSize != 42 {
+			t.Fatalf("%s: got RIFF size %d, data size %d for %d bytes of data", name, riffSize, dataSize, len(data))
+		}
+	}
+}
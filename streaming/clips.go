@@ -0,0 +1,181 @@
+package streaming
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kpeu3i/radio-streamer/radio"
+)
+
+const (
+	clipDirectoryPerm = 0755
+	clipFilePerm      = 0644
+)
+
+var (
+	ErrClipNotFound = errors.New("clip not found")
+	ErrClipTooLong  = errors.New("clip after is longer than the clip duration")
+)
+
+// ClipPolicy controls the clips: the last Duration of the station saved to
+// Dir. MP3 stations are saved as received, the other formats are converted
+// by the Transcode shell command, from stdin to MP3 on stdout, or kept in
+// their format without it.
+type ClipPolicy struct {
+	Dir       string
+	Duration  time.Duration
+	Transcode string
+}
+
+type Clip struct {
+	Name    string    `json:"name"`
+	Size    int64     `json:"size"`
+	Created time.Time `json:"created"`
+}
+
+func (s *Service) SetClipPolicy(policy ClipPolicy) {
+	s.clipPolicy = policy
+}
+
+// SaveClip saves the last minutes played and the next after duration,
+// waiting for it without blocking the other calls.
+func (s *Service) SaveClip(after time.Duration) (Clip, error) {
+	err := s.checkClipAfter(after)
+	if err != nil {
+		return Clip{}, err
+	}
+
+	clip, err := s.cutClip(time.Now(), after)
+	if err != nil {
+		return Clip{}, err
+	}
+
+	return s.storeClip(clip)
+}
+
+// checkClipAfter bounds the time waited for after the request, as the replay
+// buffer keeps all the audio received meanwhile.
+func (s *Service) checkClipAfter(after time.Duration) error {
+	if after > s.clipPolicy.Duration {
+		return fmt.Errorf("%w: %s", ErrClipTooLong, after)
+	}
+
+	return nil
+}
+
+// cutClip returns the minutes played before at and the after duration after,
+// waiting for it.
+func (s *Service) cutClip(at time.Time, after time.Duration) (radio.Clip, error) {
+	return s.radioPlayer.Clip(at.Add(-s.clipPolicy.Duration), at.Add(after))
+}
+
+func (s *Service) storeClip(clip radio.Clip) (Clip, error) {
+	err := os.MkdirAll(s.clipPolicy.Dir, clipDirectoryPerm)
+	if err != nil {
+		return Clip{}, err
+	}
+
+	name := clip.FileName(time.Now())
+
+	data := clip.Data
+	if filepath.Ext(name) != ".mp3" && s.clipPolicy.Transcode != "" {
+		data, err = transcode(s.clipPolicy.Transcode, clip.Data)
+		if err != nil {
+			return Clip{}, err
+		}
+
+		name = strings.TrimSuffix(name, filepath.Ext(name)) + ".mp3"
+	}
+
+	// The zones of a group may save the same station at the same second.
+	file, err := createClipFile(s.clipPolicy.Dir, name)
+	if err != nil {
+		return Clip{}, err
+	}
+
+	_, err = file.Write(data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return Clip{}, err
+	}
+
+	return Clip{Name: filepath.Base(file.Name()), Size: int64(len(data)), Created: time.Now()}, nil
+}
+
+// createClipFile creates the clip file, adding a number to the name when it
+// is taken.
+func createClipFile(dir, name string) (*os.File, error) {
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+
+	file, err := os.OpenFile(filepath.Join(dir, name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, clipFilePerm)
+	for i := 2; os.IsExist(err); i++ {
+		file, err = os.OpenFile(filepath.Join(dir, fmt.Sprintf("%s_%d%s", base, i, ext)), os.O_WRONLY|os.O_CREATE|os.O_EXCL, clipFilePerm)
+	}
+
+	return file, err
+}
+
+// Clips lists the saved clips, the latest first.
+func (s *Service) Clips() ([]Clip, error) {
+	files, err := ioutil.ReadDir(s.clipPolicy.Dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	clips := make([]Clip, 0, len(files))
+	for _, file := range files {
+		if file.Mode().IsRegular() {
+			clips = append(clips, Clip{Name: file.Name(), Size: file.Size(), Created: file.ModTime()})
+		}
+	}
+
+	sort.Slice(clips, func(i, j int) bool {
+		return clips[i].Created.After(clips[j].Created)
+	})
+
+	return clips, nil
+}
+
+// ClipPath returns the file of a saved clip.
+func (s *Service) ClipPath(name string) (string, error) {
+	if name == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		return "", ErrClipNotFound
+	}
+
+	path := filepath.Join(s.clipPolicy.Dir, name)
+
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return "", ErrClipNotFound
+	}
+
+	return path, nil
+}
+
+func transcode(command string, data []byte) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if err != nil {
+		return nil, fmt.Errorf("transcode (command: %s): %w: %s", command, err, strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.Bytes(), nil
+}
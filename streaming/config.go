@@ -3,18 +3,68 @@ package streaming
 import (
 	"io"
 	"os"
+	"sync"
 
 	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
-	Streams       []string `yaml:"streams"`
-	CurrentStream int      `yaml:"current_stream"`
-	CurrentVolume string   `yaml:"current_volume"`
+	Streams           []Stream             `yaml:"streams"`
+	CurrentStream     int                  `yaml:"current_stream"`
+	CurrentVolumeStep *int                 `yaml:"current_volume_step,omitempty"`
+	CurrentVolume     string               `yaml:"current_volume,omitempty"` // Deprecated: linear volume, converted to a step.
+	Filters           []Filter             `yaml:"filters,omitempty"`
+	Zones             map[string]ZoneState `yaml:"zones,omitempty"`
+}
+
+// ZoneState is the station and volume of a zone, the streams are shared.
+type ZoneState struct {
+	CurrentStream     int  `yaml:"current_stream"`
+	CurrentVolumeStep *int `yaml:"current_volume_step,omitempty"`
+}
+
+// Stream is a station of the config. It is stored as a plain URL until a
+// loudness normalization gain (dB) is learned or filters are set for it.
+type Stream struct {
+	URL     string   `yaml:"url"`
+	Gain    float64  `yaml:"gain,omitempty"`
+	Filters []Filter `yaml:"filters,omitempty"`
+}
+
+func (s *Stream) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*s = Stream{}
+
+		return value.Decode(&s.URL)
+	}
+
+	type plain Stream
+
+	return value.Decode((*plain)(s))
+}
+
+func (s Stream) MarshalYAML() (interface{}, error) {
+	if s.Gain == 0 && len(s.Filters) == 0 {
+		return s.URL, nil
+	}
+
+	type plain Stream
+
+	return plain(s), nil
+}
+
+func (c Config) StreamURLs() []string {
+	urls := make([]string, len(c.Streams))
+	for i, stream := range c.Streams {
+		urls[i] = stream.URL
+	}
+
+	return urls
 }
 
 type ConfigFileStorage struct {
 	filename string
+	mu       sync.Mutex
 }
 
 func NewConfigStorage(filename string) *ConfigFileStorage {
@@ -22,6 +72,43 @@ func NewConfigStorage(filename string) *ConfigFileStorage {
 }
 
 func (s *ConfigFileStorage) Load() (Config, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.load()
+}
+
+func (s *ConfigFileStorage) Store(config Config) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.store(config)
+}
+
+// Update changes the config as stored, without another change in between.
+func (s *ConfigFileStorage) Update(update func(config *Config) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	config, err := s.load()
+	if err != nil {
+		return err
+	}
+
+	err = update(&config)
+	if err != nil {
+		return err
+	}
+
+	return s.store(config)
+}
+
+// Zone returns the storage of a zone, sharing the file with the other zones.
+func (s *ConfigFileStorage) Zone(name string) *ZoneConfigStorage {
+	return &ZoneConfigStorage{storage: s, zone: name}
+}
+
+func (s *ConfigFileStorage) load() (Config, error) {
 	file, err := os.OpenFile(s.filename, os.O_RDONLY|os.O_CREATE, os.ModePerm)
 	if err != nil {
 		return Config{}, err
@@ -42,7 +129,7 @@ func (s *ConfigFileStorage) Load() (Config, error) {
 	return config, nil
 }
 
-func (s *ConfigFileStorage) Store(config Config) error {
+func (s *ConfigFileStorage) store(config Config) error {
 	file, err := os.OpenFile(s.filename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, os.ModePerm)
 	if err != nil {
 		return err
@@ -60,3 +147,95 @@ func (s *ConfigFileStorage) Store(config Config) error {
 
 	return nil
 }
+
+// ZoneConfigStorage keeps the current stream and volume of a zone in the
+// zones of the config. A zone without state yet starts from the top-level
+// ones, which belong to the single zone of the setups without zones. The
+// streams and filters are shared by the zones.
+type ZoneConfigStorage struct {
+	storage *ConfigFileStorage
+	zone    string
+}
+
+func (s *ZoneConfigStorage) Load() (Config, error) {
+	s.storage.mu.Lock()
+	defer s.storage.mu.Unlock()
+
+	config, err := s.storage.load()
+	if err != nil {
+		return Config{}, err
+	}
+
+	return s.zoneConfig(config), nil
+}
+
+// Store writes the state of the zone only, over the config as stored. The
+// changes of the shared parts are written with Update.
+func (s *ZoneConfigStorage) Store(config Config) error {
+	s.storage.mu.Lock()
+	defer s.storage.mu.Unlock()
+
+	stored, err := s.storage.load()
+	if err != nil {
+		return err
+	}
+
+	return s.storage.store(s.withState(stored, config))
+}
+
+// Update changes the config of the zone as stored, without another change in
+// between. The state goes to the zone, the rest is shared.
+func (s *ZoneConfigStorage) Update(update func(config *Config) error) error {
+	s.storage.mu.Lock()
+	defer s.storage.mu.Unlock()
+
+	stored, err := s.storage.load()
+	if err != nil {
+		return err
+	}
+
+	config := s.zoneConfig(stored)
+
+	err = update(&config)
+	if err != nil {
+		return err
+	}
+
+	shared := config
+	shared.CurrentStream = stored.CurrentStream
+	shared.CurrentVolumeStep = stored.CurrentVolumeStep
+	shared.CurrentVolume = stored.CurrentVolume
+
+	return s.storage.store(s.withState(shared, config))
+}
+
+// zoneConfig returns the config with the state of the zone at the top level.
+func (s *ZoneConfigStorage) zoneConfig(config Config) Config {
+	if state, ok := config.Zones[s.zone]; ok {
+		config.CurrentStream = state.CurrentStream
+		if state.CurrentVolumeStep != nil {
+			config.CurrentVolumeStep = state.CurrentVolumeStep
+			config.CurrentVolume = ""
+		}
+	}
+
+	return config
+}
+
+// withState returns the stored config with the state of the zone taken from
+// the zone config.
+func (s *ZoneConfigStorage) withState(stored, config Config) Config {
+	zones := make(map[string]ZoneState, len(stored.Zones)+1)
+	for name, state := range stored.Zones {
+		zones[name] = state
+	}
+
+	zones[s.zone] = ZoneState{
+		CurrentStream:     config.CurrentStream,
+		CurrentVolumeStep: config.CurrentVolumeStep,
+	}
+
+	stored.Zones = zones
+
+	return stored
+}
@@ -0,0 +1,170 @@
+package streaming
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/kpeu3i/radio-streamer/radio"
+)
+
+const (
+	maxFilterGain = 24.0
+)
+
+var ErrFilterNotFound = errors.New("filter not found")
+
+// Filter is a filter of a DSP chain, see radio.FilterSpec. The filters with
+// a name can be adjusted through the APIs.
+type Filter struct {
+	Name      string  `yaml:"name,omitempty" json:"name,omitempty"`
+	Type      string  `yaml:"type" json:"type"`
+	Frequency float64 `yaml:"frequency,omitempty" json:"frequency,omitempty"`
+	Gain      float64 `yaml:"gain,omitempty" json:"gain,omitempty"`
+	Q         float64 `yaml:"q,omitempty" json:"q,omitempty"`
+	Value     float64 `yaml:"value,omitempty" json:"value,omitempty"`
+}
+
+func filterSpecs(filters []Filter) []radio.FilterSpec {
+	specs := make([]radio.FilterSpec, len(filters))
+	for i, filter := range filters {
+		specs[i] = radio.FilterSpec{
+			Name:      filter.Name,
+			Type:      filter.Type,
+			Frequency: filter.Frequency,
+			Gain:      filter.Gain,
+			Q:         filter.Q,
+			Value:     filter.Value,
+		}
+	}
+
+	return specs
+}
+
+// Filters returns the DSP chain applied to all the streams.
+func (s *Service) Filters() ([]Filter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	config, err := s.configStorage.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	return config.Filters, nil
+}
+
+// SetFilters replaces the DSP chain applied to all the streams.
+func (s *Service) SetFilters(filters []Filter) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.storeFilters(func(config *Config) error {
+		config.Filters = filters
+
+		return nil
+	})
+}
+
+// AdjustFilterGain changes the gain of the named filter by the dB, like
+// "bass" by 3 dB. The filters of the current stream are looked up after
+// the global ones.
+func (s *Service) AdjustFilterGain(name string, gain float64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.storeFilters(func(config *Config) error {
+		filter := findFilter(config.Filters, name)
+		if filter == nil {
+			index := config.CurrentStream - 1
+			if index >= 0 && index < len(config.Streams) {
+				filter = findFilter(config.Streams[index].Filters, name)
+			}
+		}
+
+		if filter == nil {
+			return fmt.Errorf("%w: %s", ErrFilterNotFound, name)
+		}
+
+		filter.Gain = math.Max(-maxFilterGain, math.Min(filter.Gain+gain, maxFilterGain))
+
+		return nil
+	})
+}
+
+// storeFilters changes the filters of the config, and applies the DSP chains
+// to the player before storing them, so invalid filters are neither played nor
+// stored. The other zones apply them once stored.
+func (s *Service) storeFilters(update func(config *Config) error) error {
+	err := s.configStorage.Update(func(config *Config) error {
+		err := update(config)
+		if err != nil {
+			return err
+		}
+
+		return s.applyFilters(*config)
+	})
+	if err != nil {
+		return err
+	}
+
+	if s.filtersHandler != nil {
+		// The other zones take their own locks.
+		go s.filtersHandler()
+	}
+
+	return nil
+}
+
+// reloadFilters applies the filters as stored, changed by another zone.
+func (s *Service) reloadFilters() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	config, err := s.configStorage.Load()
+	if err != nil {
+		return err
+	}
+
+	return s.applyFilters(config)
+}
+
+// applyFilters checks all the DSP chains first, so the player keeps the
+// previous ones if any of them is invalid.
+func (s *Service) applyFilters(config Config) error {
+	err := radio.ValidateFilters(filterSpecs(config.Filters)...)
+	if err != nil {
+		return err
+	}
+
+	for _, stream := range config.Streams {
+		err = radio.ValidateFilters(filterSpecs(stream.Filters)...)
+		if err != nil {
+			return fmt.Errorf("stream %s: %w", stream.URL, err)
+		}
+	}
+
+	err = s.radioPlayer.SetFilters(filterSpecs(config.Filters)...)
+	if err != nil {
+		return err
+	}
+
+	for _, stream := range config.Streams {
+		err = s.radioPlayer.SetStreamFilters(stream.URL, filterSpecs(stream.Filters)...)
+		if err != nil {
+			return fmt.Errorf("stream %s: %w", stream.URL, err)
+		}
+	}
+
+	return nil
+}
+
+func findFilter(filters []Filter, name string) *Filter {
+	for i := range filters {
+		if filters[i].Name == name {
+			return &filters[i]
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,75 @@
+package streaming
+
+import (
+	"time"
+)
+
+type LevelsHandler func(levels Levels)
+
+// Levels are the RMS and peak levels per channel of the audio going to the
+// output, in dBFS.
+type Levels struct {
+	Playing bool      `json:"playing"`
+	RMS     []float64 `json:"rms"`
+	Peak    []float64 `json:"peak"`
+}
+
+func (s *Service) Levels() Levels {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.levels()
+}
+
+// OnLevels calls the handler with the levels at the interval while the radio
+// is playing, and once when it stops, until the service is closed.
+func (s *Service) OnLevels(handler LevelsHandler, interval time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.levelsStop != nil {
+		close(s.levelsStop)
+		s.levelsStop = nil
+	}
+
+	if interval <= 0 {
+		return
+	}
+
+	stop := make(chan struct{})
+	s.levelsStop = stop
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		playing := false
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				s.mu.Lock()
+				levels := s.levels()
+				s.mu.Unlock()
+
+				if levels.Playing || playing {
+					handler(levels)
+				}
+
+				playing = levels.Playing
+			}
+		}
+	}()
+}
+
+func (s *Service) levels() Levels {
+	levels := s.radioPlayer.Levels()
+
+	return Levels{
+		Playing: s.radioPlayer.IsPlaying(),
+		RMS:     levels.RMS,
+		Peak:    levels.Peak,
+	}
+}
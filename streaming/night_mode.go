@@ -0,0 +1,120 @@
+package streaming
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	nightScheduleInterval = 30 * time.Second
+)
+
+// NightSchedule is the daily time range the night mode is on, as offsets
+// from midnight. The range can span midnight, like 22:00 to 07:00. An empty
+// range disables the schedule.
+type NightSchedule struct {
+	Start time.Duration
+	End   time.Duration
+}
+
+// ParseNightSchedule parses a range of "15:04" times, both empty for none.
+func ParseNightSchedule(start, end string) (NightSchedule, error) {
+	if start == "" && end == "" {
+		return NightSchedule{}, nil
+	}
+
+	var schedule NightSchedule
+
+	for _, v := range []struct {
+		value  string
+		offset *time.Duration
+	}{{start, &schedule.Start}, {end, &schedule.End}} {
+		t, err := time.Parse("15:04", v.value)
+		if err != nil {
+			return NightSchedule{}, fmt.Errorf("invalid night mode time: %q", v.value)
+		}
+
+		*v.offset = time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	}
+
+	return schedule, nil
+}
+
+func (s NightSchedule) Enabled() bool {
+	return s.Start != s.End
+}
+
+func (s NightSchedule) Contains(t time.Time) bool {
+	offset := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+
+	if s.Start < s.End {
+		return offset >= s.Start && offset < s.End
+	}
+
+	return offset >= s.Start || offset < s.End
+}
+
+func (s *Service) NightMode() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.radioPlayer.NightMode()
+}
+
+// SetNightMode turns the night mode compressor on or off. The schedule
+// changes it again at the next start or end of the night.
+func (s *Service) SetNightMode(enabled bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.radioPlayer.SetNightMode(enabled)
+}
+
+func (s *Service) ToggleNightMode() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	enabled := !s.radioPlayer.NightMode()
+	s.radioPlayer.SetNightMode(enabled)
+
+	return enabled
+}
+
+// SetNightSchedule turns the night mode on within the hours of the schedule,
+// and off outside of them, until the service is closed.
+func (s *Service) SetNightSchedule(schedule NightSchedule) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.nightStop != nil {
+		close(s.nightStop)
+		s.nightStop = nil
+	}
+
+	if !schedule.Enabled() {
+		return
+	}
+
+	stop := make(chan struct{})
+	s.nightStop = stop
+
+	go func() {
+		ticker := time.NewTicker(nightScheduleInterval)
+		defer ticker.Stop()
+
+		night := schedule.Contains(time.Now())
+		s.SetNightMode(night)
+
+		for {
+			select {
+			case <-stop:
+				return
+			case now := <-ticker.C:
+				if schedule.Contains(now) != night {
+					night = !night
+					s.SetNightMode(night)
+				}
+			}
+		}
+	}()
+}
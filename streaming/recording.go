@@ -0,0 +1,81 @@
+package streaming
+
+import (
+	"time"
+
+	"github.com/kpeu3i/radio-streamer/radio"
+)
+
+// Recording describes the current recording, or the last one with the error
+// that stopped it.
+type Recording struct {
+	Recording bool       `json:"recording"`
+	Stream    string     `json:"stream,omitempty"`
+	File      string     `json:"file,omitempty"`
+	Started   *time.Time `json:"started,omitempty"`
+	Size      int64      `json:"size"`
+	Error     string     `json:"error,omitempty"`
+}
+
+func (s *Service) SetRecordingPolicy(policy radio.RecordingPolicy) {
+	s.recordingPolicy = policy
+}
+
+// StartRecording records the current station to disk, as received.
+func (s *Service) StartRecording() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.radioPlayer.StartRecording(s.recordingPolicy)
+}
+
+func (s *Service) StopRecording() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.radioPlayer.StopRecording()
+}
+
+// ToggleRecording starts or stops the recording, and reports whether it is
+// recording now.
+func (s *Service) ToggleRecording() (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.radioPlayer.Recording().Recording {
+		s.radioPlayer.StopRecording()
+
+		return false, nil
+	}
+
+	err := s.radioPlayer.StartRecording(s.recordingPolicy)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (s *Service) Recording() Recording {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := s.radioPlayer.Recording()
+
+	recording := Recording{
+		Recording: status.Recording,
+		Stream:    status.Stream,
+		File:      status.File,
+		Size:      status.Size,
+	}
+
+	if !status.Started.IsZero() {
+		recording.Started = &status.Started
+	}
+
+	if status.Err != nil {
+		recording.Error = status.Err.Error()
+	}
+
+	return recording
+}
@@ -0,0 +1,14 @@
+package streaming
+
+import (
+	"github.com/kpeu3i/radio-streamer/radio"
+)
+
+// Relay adds a listener of the raw stream played, which keeps receiving it
+// across the station switches of the same format.
+func (s *Service) Relay() (*radio.RelayListener, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.radioPlayer.Relay()
+}
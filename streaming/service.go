@@ -1,9 +1,12 @@
 package streaming
 
 import (
-	"fmt"
+	"log"
+	"math"
 	"strconv"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/kpeu3i/radio-streamer/radio"
 )
@@ -11,6 +14,7 @@ import (
 type ConfigStorage interface {
 	Load() (Config, error)
 	Store(config Config) error
+	Update(update func(config *Config) error) error
 }
 
 type RadioPlayer interface {
@@ -19,20 +23,141 @@ type RadioPlayer interface {
 	Next() int
 	IsPlaying() bool
 	OnError(handler radio.ErrorHandler)
+	OnMetadata(handler radio.MetadataHandler)
+	OnStreamGain(handler radio.StreamGainHandler)
+	OnStreamChange(handler radio.StreamHandler)
+	SetStreamGain(stream string, gain float64)
+	SetFilters(filters ...radio.FilterSpec) error
+	SetStreamFilters(stream string, filters ...radio.FilterSpec) error
+	NightMode() bool
+	SetNightMode(enabled bool)
+	Metadata() radio.Metadata
+	BufferStats() radio.BufferStats
+	Pause() error
+	Resume() error
+	GoLive() error
+	Levels() radio.Levels
+	StartRecording(policy radio.RecordingPolicy) error
+	StopRecording()
+	Recording() radio.RecordingStatus
+	Clip(from, to time.Time) (radio.Clip, error)
+	Relay() (*radio.RelayListener, error)
 	Volume() float64
 	SetVolume(v float64)
+	FadeVolume(v float64, duration time.Duration)
 	Stop()
 	Close() error
 }
 
+type NowPlayingHandler func(nowPlaying NowPlaying)
+
+type NowPlaying struct {
+	Playing bool   `json:"playing"`
+	Stream  int    `json:"stream,omitempty"`
+	URL     string `json:"url,omitempty"`
+	Title   string `json:"title,omitempty"`
+	Name    string `json:"name,omitempty"`
+	Genre   string `json:"genre,omitempty"`
+	Bitrate int    `json:"bitrate,omitempty"`
+}
+
+type BufferStats struct {
+	Size      float64 `json:"size"`
+	Level     float64 `json:"level"`
+	Buffering bool    `json:"buffering"`
+	Underruns int     `json:"underruns"`
+	Paused    bool    `json:"paused"`
+	Behind    float64 `json:"behind"`
+}
+
 type Service struct {
-	configStorage ConfigStorage
-	radioPlayer   RadioPlayer
-	mu            sync.Mutex
+	configStorage     ConfigStorage
+	radioPlayer       RadioPlayer
+	nowPlayingHandler NowPlayingHandler
+	filtersHandler    func()
+	volumeCurve       VolumeCurve
+	volumeFade        time.Duration
+	recordingPolicy   radio.RecordingPolicy
+	clipPolicy        ClipPolicy
+	nightStop         chan struct{}
+	levelsStop        chan struct{}
+	streamChanges     uint64
+	mu                sync.Mutex
 }
 
 func NewService(configStorage ConfigStorage, radioPlayer RadioPlayer) *Service {
-	return &Service{configStorage: configStorage, radioPlayer: radioPlayer}
+	service := &Service{configStorage: configStorage, radioPlayer: radioPlayer, volumeCurve: DefaultVolumeCurve()}
+	radioPlayer.OnMetadata(func(metadata radio.Metadata) {
+		service.notifyNowPlaying(newNowPlaying(metadata))
+	})
+	radioPlayer.OnStreamGain(func(stream string, gain float64) {
+		// The player reports the gain while stopping, possibly within a service call.
+		go func() {
+			err := service.storeStreamGain(stream, gain)
+			if err != nil {
+				log.Printf("[ERROR] %v\n", err)
+			}
+		}()
+	})
+	radioPlayer.OnStreamChange(func(num int) {
+		// The player reports the switches from its goroutine, which a service call may wait for.
+		change := service.changeStream()
+		go func() {
+			err := service.storeStream(num, change)
+			if err != nil {
+				log.Printf("[ERROR] %v\n", err)
+			}
+		}()
+	})
+
+	return service
+}
+
+func (s *Service) OnNowPlaying(handler NowPlayingHandler) {
+	s.nowPlayingHandler = handler
+}
+
+// OnFiltersChange sets the handler called once the filters shared with the
+// other zones have changed.
+func (s *Service) OnFiltersChange(handler func()) {
+	s.filtersHandler = handler
+}
+
+func (s *Service) SetVolumeCurve(curve VolumeCurve) {
+	s.volumeCurve = curve
+}
+
+// SetVolumeFade sets how long the volume glides to the new level on volume up/down.
+func (s *Service) SetVolumeFade(duration time.Duration) {
+	s.volumeFade = duration
+}
+
+func (s *Service) NowPlaying() NowPlaying {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.radioPlayer.IsPlaying() {
+		return NowPlaying{}
+	}
+
+	return newNowPlaying(s.radioPlayer.Metadata())
+}
+
+// BufferStats reports the playback buffer, the durations are in seconds.
+func (s *Service) BufferStats() BufferStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := s.radioPlayer.BufferStats()
+
+	return BufferStats{
+		Size:      stats.Size.Seconds(),
+		Level:     stats.Level.Seconds(),
+		Buffering: stats.Buffering,
+		Underruns: stats.Underruns,
+		Paused:    stats.Paused,
+		Behind:    stats.Behind.Seconds(),
+	}
 }
 
 func (s *Service) PlayRadio() error {
@@ -48,12 +173,16 @@ func (s *Service) PlayRadio() error {
 		return err
 	}
 
-	volume, err := strconv.ParseFloat(config.CurrentVolume, 64)
+	for _, stream := range config.Streams {
+		s.radioPlayer.SetStreamGain(stream.URL, stream.Gain)
+	}
+
+	err = s.applyFilters(config)
 	if err != nil {
 		return err
 	}
 
-	s.radioPlayer.SetVolume(volume)
+	s.radioPlayer.SetVolume(s.volumeCurve.Gain(s.volumeStep(config)))
 	s.radioPlayer.Play(config.CurrentStream)
 
 	return nil
@@ -68,6 +197,7 @@ func (s *Service) StopRadio() {
 	}
 
 	s.radioPlayer.Stop()
+	s.notifyNowPlaying(NowPlaying{})
 }
 
 func (s *Service) IsRadioPlaying() bool {
@@ -81,107 +211,165 @@ func (s *Service) PrevRadioStream() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	num := s.radioPlayer.Prev()
+	// The stream is stored once it plays.
+	s.radioPlayer.Prev()
 
-	config, err := s.configStorage.Load()
-	if err != nil {
-		return err
-	}
+	return nil
+}
 
-	config.CurrentStream = num
+func (s *Service) NextRadioStream() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	err = s.configStorage.Store(config)
+	// The stream is stored once it plays.
+	s.radioPlayer.Next()
+
+	return nil
+}
+
+// UpVolume raises the volume by the number of steps of the volume curve.
+func (s *Service) UpVolume(steps int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	config, err := s.configStorage.Load()
 	if err != nil {
 		return err
 	}
 
-	return nil
+	return s.setVolumeStep(config, s.volumeStep(config)+steps, s.volumeFade)
 }
 
-func (s *Service) NextRadioStream() error {
+// DownVolume lowers the volume by the number of steps of the volume curve.
+func (s *Service) DownVolume(steps int) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	num := s.radioPlayer.Next()
-
 	config, err := s.configStorage.Load()
 	if err != nil {
 		return err
 	}
 
-	config.CurrentStream = num
+	return s.setVolumeStep(config, s.volumeStep(config)-steps, s.volumeFade)
+}
 
-	err = s.configStorage.Store(config)
+// FadeVolume changes the volume gradually to the step of the volume curve over the duration.
+func (s *Service) FadeVolume(step int, duration time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	config, err := s.configStorage.Load()
 	if err != nil {
 		return err
 	}
 
-	return nil
+	return s.setVolumeStep(config, step, duration)
 }
 
-func (s *Service) UpVolume(step float64) error {
+func (s *Service) Close() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	volume := s.radioPlayer.Volume()
-
-	volume += step
-	if volume > 1 {
-		volume = 1
+	if s.nightStop != nil {
+		close(s.nightStop)
+		s.nightStop = nil
 	}
 
-	s.radioPlayer.SetVolume(volume)
-
-	config, err := s.configStorage.Load()
-	if err != nil {
-		return err
+	if s.levelsStop != nil {
+		close(s.levelsStop)
+		s.levelsStop = nil
 	}
 
-	config.CurrentVolume = fmt.Sprintf("%.2f", volume)
+	s.radioPlayer.StopRecording()
 
-	err = s.configStorage.Store(config)
-	if err != nil {
-		return err
+	if !s.radioPlayer.IsPlaying() {
+		return nil
 	}
 
-	return nil
+	return s.radioPlayer.Close()
 }
 
-func (s *Service) DownVolume(step float64) error {
+// changeStream counts the stream changes, so a stream stored after a later
+// change is dropped.
+func (s *Service) changeStream() uint64 {
+	return atomic.AddUint64(&s.streamChanges, 1)
+}
+
+// storeStream stores the stream the player switched to, unless the stream has
+// changed again since.
+func (s *Service) storeStream(num int, change uint64) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	volume := s.radioPlayer.Volume()
-
-	volume -= step
-	if volume < 0 {
-		volume = 0
+	if atomic.LoadUint64(&s.streamChanges) != change {
+		return nil
 	}
 
-	s.radioPlayer.SetVolume(volume)
-
 	config, err := s.configStorage.Load()
 	if err != nil {
 		return err
 	}
 
-	config.CurrentVolume = fmt.Sprintf("%.2f", volume)
-
-	err = s.configStorage.Store(config)
-	if err != nil {
-		return err
-	}
+	config.CurrentStream = num
 
-	return nil
+	return s.configStorage.Store(config)
 }
 
-func (s *Service) Close() error {
+func (s *Service) storeStreamGain(stream string, gain float64) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if !s.radioPlayer.IsPlaying() {
+	return s.configStorage.Update(func(config *Config) error {
+		for i := range config.Streams {
+			if config.Streams[i].URL == stream {
+				config.Streams[i].Gain = math.Round(gain*10) / 10
+			}
+		}
+
 		return nil
+	})
+}
+
+func (s *Service) setVolumeStep(config Config, step int, duration time.Duration) error {
+	step = s.volumeCurve.Clamp(step)
+
+	s.radioPlayer.FadeVolume(s.volumeCurve.Gain(step), duration)
+
+	config.CurrentVolumeStep = &step
+	config.CurrentVolume = ""
+
+	return s.configStorage.Store(config)
+}
+
+// volumeStep returns the stored volume step, converting the linear volume
+// stored by the previous versions.
+func (s *Service) volumeStep(config Config) int {
+	if config.CurrentVolumeStep != nil {
+		return s.volumeCurve.Clamp(*config.CurrentVolumeStep)
 	}
 
-	return s.radioPlayer.Close()
+	volume, err := strconv.ParseFloat(config.CurrentVolume, 64)
+	if err != nil {
+		return s.volumeCurve.Steps
+	}
+
+	return s.volumeCurve.Step(volume)
+}
+
+func (s *Service) notifyNowPlaying(nowPlaying NowPlaying) {
+	if s.nowPlayingHandler != nil {
+		s.nowPlayingHandler(nowPlaying)
+	}
+}
+
+func newNowPlaying(metadata radio.Metadata) NowPlaying {
+	return NowPlaying{
+		Playing: true,
+		Stream:  metadata.Stream,
+		URL:     metadata.URL,
+		Title:   metadata.Title,
+		Name:    metadata.Name,
+		Genre:   metadata.Genre,
+		Bitrate: metadata.Bitrate,
+	}
 }
@@ -0,0 +1,36 @@
+package streaming
+
+// PauseRadio silences the radio while the station keeps being received, so
+// it resumes where it was paused.
+func (s *Service) PauseRadio() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.radioPlayer.Pause()
+}
+
+func (s *Service) ResumeRadio() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.radioPlayer.Resume()
+}
+
+func (s *Service) TogglePause() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.radioPlayer.BufferStats().Paused {
+		return s.radioPlayer.Resume()
+	}
+
+	return s.radioPlayer.Pause()
+}
+
+// GoLive skips what was missed during the pauses.
+func (s *Service) GoLive() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.radioPlayer.GoLive()
+}
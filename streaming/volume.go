@@ -0,0 +1,72 @@
+package streaming
+
+import (
+	"fmt"
+	"math"
+)
+
+// VolumeCurve maps volume steps to player gains. Step 0 mutes, the steps
+// from 1 to Steps are evenly spaced in decibels from MinDB to 0 dB, so each
+// step sounds like the same change.
+type VolumeCurve struct {
+	Steps int
+	MinDB float64
+}
+
+func DefaultVolumeCurve() VolumeCurve {
+	return VolumeCurve{Steps: 20, MinDB: -50}
+}
+
+// Validate rejects curves without steps or with a minimum above 0 dB, which
+// would mute or distort every step.
+func (c VolumeCurve) Validate() error {
+	if c.Steps < 1 {
+		return fmt.Errorf("invalid volume steps: %d", c.Steps)
+	}
+
+	if c.MinDB >= 0 {
+		return fmt.Errorf("invalid volume min dB: %g", c.MinDB)
+	}
+
+	return nil
+}
+
+func (c VolumeCurve) Gain(step int) float64 {
+	step = c.Clamp(step)
+
+	switch {
+	case step == 0:
+		return 0
+	case c.Steps == 1:
+		return 1
+	}
+
+	db := c.MinDB * float64(c.Steps-step) / float64(c.Steps-1)
+
+	return math.Pow(10, db/20)
+}
+
+// Step returns the step closest to the gain, to migrate linear volumes.
+func (c VolumeCurve) Step(gain float64) int {
+	best, distance := 0, math.Inf(1)
+
+	for step := 0; step <= c.Steps; step++ {
+		if d := math.Abs(c.Gain(step) - gain); d < distance {
+			best, distance = step, d
+		}
+	}
+
+	return best
+}
+
+func (c VolumeCurve) Clamp(step int) int {
+	if step < 0 {
+		return 0
+	}
+
+	if step > c.Steps {
+		return c.Steps
+	}
+
+	return step
+}
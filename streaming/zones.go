@@ -0,0 +1,259 @@
+package streaming
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kpeu3i/radio-streamer/radio"
+)
+
+// ZoneGroupAll selects all the zones.
+const ZoneGroupAll = "all"
+
+var ErrZoneNotFound = errors.New("zone not found")
+
+// Zone is an output of the house playing with its own service.
+type Zone struct {
+	Name    string
+	Service *Service
+}
+
+// Zones are the zones in the order they are added, the first one is the
+// default zone.
+type Zones struct {
+	zones []Zone
+}
+
+func NewZones() *Zones {
+	return &Zones{}
+}
+
+// Add adds the zone. The filters are shared, a change through a zone is
+// applied to the other ones.
+func (z *Zones) Add(name string, service *Service) *Zones {
+	z.zones = append(z.zones, Zone{Name: name, Service: service})
+
+	service.OnFiltersChange(func() {
+		for _, zone := range z.zones {
+			if zone.Service == service {
+				continue
+			}
+
+			err := zone.Service.reloadFilters()
+			if err != nil {
+				log.Printf("[ERROR] zone %s: %v\n", zone.Name, err)
+			}
+		}
+	})
+
+	return z
+}
+
+func (z *Zones) All() Group {
+	return append(Group(nil), z.zones...)
+}
+
+// Zone returns the zone of the name, or the default zone for an empty name.
+func (z *Zones) Zone(name string) (Zone, error) {
+	if len(z.zones) == 0 {
+		return Zone{}, ErrZoneNotFound
+	}
+
+	if name == "" {
+		return z.zones[0], nil
+	}
+
+	for _, zone := range z.zones {
+		if zone.Name == name {
+			return zone, nil
+		}
+	}
+
+	return Zone{}, fmt.Errorf("%w: %s", ErrZoneNotFound, name)
+}
+
+// Group returns the zones of the selector: a zone name, names separated by
+// commas, or "all". An empty selector is the default zone.
+func (z *Zones) Group(selector string) (Group, error) {
+	if selector == ZoneGroupAll {
+		return z.All(), nil
+	}
+
+	var group Group
+
+	for _, name := range strings.Split(selector, ",") {
+		zone, err := z.Zone(strings.TrimSpace(name))
+		if err != nil {
+			return nil, err
+		}
+
+		if !group.contains(zone.Name) {
+			group = append(group, zone)
+		}
+	}
+
+	return group, nil
+}
+
+// Close closes the services of all the zones.
+func (z *Zones) Close() error {
+	var errs []string
+
+	for _, zone := range z.zones {
+		err := zone.Service.Close()
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", zone.Name, err))
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+
+	return nil
+}
+
+// ZoneErrors are the errors of the zones of a group that failed, by zone.
+// The other zones of the group have acted.
+type ZoneErrors map[string]error
+
+func (e ZoneErrors) Error() string {
+	names := make([]string, 0, len(e))
+	for name := range e {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	errs := make([]string, len(names))
+	for i, name := range names {
+		errs[i] = fmt.Sprintf("%s: %s", name, e[name])
+	}
+
+	return strings.Join(errs, "; ")
+}
+
+// Group is a set of zones controlled together.
+type Group []Zone
+
+// IsRadioPlaying reports whether any zone of the group is playing.
+func (g Group) IsRadioPlaying() bool {
+	for _, zone := range g {
+		if zone.Service.IsRadioPlaying() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsRecording reports whether any zone of the group is recording.
+func (g Group) IsRecording() bool {
+	for _, zone := range g {
+		if zone.Service.Recording().Recording {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SaveClip saves the same moment on all the zones of the group. The zones
+// wait for the after duration together and are cut at once, then the clips
+// are saved. The clips saved are returned along with the zones failing, as
+// ZoneErrors.
+func (g Group) SaveClip(after time.Duration) (map[string]Clip, error) {
+	for _, zone := range g {
+		err := zone.Service.checkClipAfter(after)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	at := time.Now()
+	cuts := make([]radio.Clip, len(g))
+	errs := make([]error, len(g))
+
+	var wg sync.WaitGroup
+
+	for i, zone := range g {
+		wg.Add(1)
+
+		go func(i int, service *Service) {
+			defer wg.Done()
+
+			cuts[i], errs[i] = service.cutClip(at, after)
+		}(i, zone.Service)
+	}
+
+	wg.Wait()
+
+	clips := make(map[string]Clip, len(g))
+	failed := make(ZoneErrors)
+
+	for i, zone := range g {
+		if errs[i] != nil {
+			failed[zone.Name] = errs[i]
+
+			continue
+		}
+
+		clip, err := zone.Service.storeClip(cuts[i])
+		if err != nil {
+			failed[zone.Name] = err
+
+			continue
+		}
+
+		clips[zone.Name] = clip
+	}
+
+	if len(failed) > 0 {
+		return clips, failed
+	}
+
+	return clips, nil
+}
+
+// TogglePower stops all the zones when any of them is playing, and starts
+// them all otherwise, so the group ends up in the same state. Every zone is
+// started, the zones failing to are returned as ZoneErrors.
+func (g Group) TogglePower() error {
+	if g.IsRadioPlaying() {
+		for _, zone := range g {
+			zone.Service.StopRadio()
+		}
+
+		return nil
+	}
+
+	errs := make(ZoneErrors)
+
+	for _, zone := range g {
+		err := zone.Service.PlayRadio()
+		if err != nil {
+			errs[zone.Name] = err
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+func (g Group) contains(name string) bool {
+	for _, zone := range g {
+		if zone.Name == name {
+			return true
+		}
+	}
+
+	return false
+}